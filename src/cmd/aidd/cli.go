@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/module/agent"
+	"github.com/tomoyuki65/go-aidd/internal/module/runner"
+	mt "github.com/tomoyuki65/go-aidd/internal/module/task"
+	"github.com/tomoyuki65/go-aidd/internal/util/sandbox"
+)
+
+// Exit codes of the subcommands
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitUsage     = 2
+	exitCancelled = 130
+)
+
+const usageText = `Usage:
+  aidd                                  Start the TUI
+  aidd generate [--json]                Retrieve issues and generate/update task.md
+  aidd list [--json] [--sort <order>]   List the tasks (in task.md or the task files of the local provider)
+                                        sorted by file (default), number, priority or status
+  aidd run [--json] <number...>         Run the given tasks (in parallel up to task.max_concurrency)
+  aidd revise [--json] -m <details> <branch>
+                                        Apply an additional revision to a completed task branch
+  aidd completed [--json]               List the branches of completed tasks
+  aidd agent --prompt-file <file> [--model <model>] [--base-url <url>] [--allow <command>...]
+                                        Run the built-in agent in the current directory
+  aidd help                             Show this help
+
+Exit codes:
+  0: success / 1: a command or task failed / 2: invalid arguments / 130: cancelled
+`
+
+// Result of a task or revision run in JSON output
+type runResultJSON struct {
+	Name          string  `json:"name"`
+	Number        string  `json:"number,omitempty"`
+	Branch        string  `json:"branch,omitempty"`
+	Status        string  `json:"status"`
+	Error         string  `json:"error,omitempty"`
+	Workspace     string  `json:"workspace,omitempty"`
+	TranscriptDir string  `json:"transcript_dir,omitempty"`
+	PRURL         string  `json:"pr_url,omitempty"`
+	Seconds       float64 `json:"seconds"`
+}
+
+// Task in JSON output
+type taskJSON struct {
+	Number     string   `json:"number"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Labels     []string `json:"labels,omitempty"`
+	AIType     string   `json:"ai_type,omitempty"`
+	AIModel    string   `json:"ai_model,omitempty"`
+	DependsOn  []string `json:"depends_on,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	Priority   string   `json:"priority,omitempty"`
+	BaseBranch string   `json:"base_branch,omitempty"`
+}
+
+// Completed task in JSON output
+type completedTaskJSON struct {
+	BranchName string `json:"branch_name"`
+}
+
+// Error in JSON output
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// Execute a subcommand without the TUI and return the exit code
+func runCommand(args []string) int {
+	name, args := args[0], args[1:]
+
+	switch name {
+	case sandbox.Command:
+		// Re-executed by a task to run the AI tool in the sandbox (not listed in the usage)
+		return sandbox.Main(args)
+	case agent.Command:
+		// Built-in AI tool (ai.type: aidd) run by a task in the workspace
+		return agent.Main(args)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usageText)
+		return exitOK
+	case "generate", "list", "run", "revise", "completed":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usageText)
+		return exitUsage
+	}
+
+	// Parse the options of the subcommand
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOutput := fs.Bool("json", false, "output the result in JSON format")
+	revisionDetails := fs.String("m", "", "revision details")
+	sortOrder := fs.String("sort", mt.SortByFile, "order of the task list")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usageText)
+		return exitUsage
+	}
+
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Cancel the runs on Ctrl+C / SIGTERM so no AI process is left running
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch name {
+	case "generate":
+		return commandGenerate(cfg, *jsonOutput)
+	case "list":
+		return commandList(cfg, *jsonOutput, *sortOrder)
+	case "run":
+		return commandRun(ctx, cfg, positional, *jsonOutput)
+	case "revise":
+		return commandRevise(ctx, cfg, positional, *revisionDetails, *jsonOutput)
+	default:
+		return commandCompleted(*jsonOutput)
+	}
+}
+
+// Parse flags that may appear before or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// Write the value to stdout as indented JSON
+func printJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// Report an error and return the failure exit code
+func commandError(jsonOutput bool, err error) int {
+	if jsonOutput {
+		printJSON(errorJSON{Error: err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "An error occurred: %v\n", err)
+	}
+
+	return exitFailure
+}
+
+// aidd generate
+func commandGenerate(cfg *config.Config, jsonOutput bool) int {
+	if err := mt.GenerateTaskMd(cfg); err != nil {
+		return commandError(jsonOutput, err)
+	}
+
+	if jsonOutput {
+		printJSON(map[string]string{"status": "ok"})
+	}
+
+	return exitOK
+}
+
+// aidd list
+func commandList(cfg *config.Config, jsonOutput bool, sortOrder string) int {
+	tasks, err := mt.LoadTasks(cfg)
+	if err != nil {
+		return commandError(jsonOutput, err)
+	}
+
+	tasks, err = mt.SortTasks(tasks, sortOrder)
+	if err != nil {
+		return commandError(jsonOutput, err)
+	}
+
+	if jsonOutput {
+		output := []taskJSON{}
+		for _, task := range tasks {
+			output = append(output, taskJSON{
+				Number:     task.Number,
+				Title:      task.Title,
+				Body:       task.Body,
+				Labels:     task.Labels,
+				AIType:     task.AIType,
+				AIModel:    task.AIModel,
+				DependsOn:  task.DependsOn,
+				Status:     task.Status,
+				Priority:   task.Priority,
+				BaseBranch: task.BaseBranch,
+			})
+		}
+		printJSON(output)
+		return exitOK
+	}
+
+	for _, task := range tasks {
+		fmt.Printf("%s\t%s\n", task.Number, task.Title)
+	}
+
+	return exitOK
+}
+
+// aidd completed
+func commandCompleted(jsonOutput bool) int {
+	// No completed_tasks.txt just means there are no completed tasks yet
+	completedTasks, err := mt.LoadCompletedTasks()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return commandError(jsonOutput, err)
+	}
+
+	if jsonOutput {
+		output := []completedTaskJSON{}
+		for _, completedTask := range completedTasks {
+			output = append(output, completedTaskJSON{BranchName: completedTask.BranchName})
+		}
+		printJSON(output)
+		return exitOK
+	}
+
+	for _, completedTask := range completedTasks {
+		fmt.Println(completedTask.BranchName)
+	}
+
+	return exitOK
+}
+
+// aidd run <number...>
+func commandRun(ctx context.Context, cfg *config.Config, numbers []string, jsonOutput bool) int {
+	if len(numbers) == 0 {
+		fmt.Fprintf(os.Stderr, "please specify the numbers of the tasks to run\n\n%s", usageText)
+		return exitUsage
+	}
+
+	tasks, err := mt.LoadTasks(cfg)
+	if err != nil {
+		return commandError(jsonOutput, err)
+	}
+
+	// Find the tasks to run
+	tasksByNumber := map[string]mt.Task{}
+	for _, task := range tasks {
+		tasksByNumber[task.Number] = task
+	}
+
+	var jobs []runner.Job
+	var runs []*mt.Run
+	seen := map[string]bool{}
+	for _, number := range numbers {
+		// Run each task once even if its number is repeated (runs of the same task share its branch)
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+
+		task, ok := tasksByNumber[number]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "task %s not found\n", number)
+			return exitUsage
+		}
+
+		run := mt.NewRun(fmt.Sprintf("Task %s. %s", task.Number, task.Title))
+		runs = append(runs, run)
+		jobs = append(jobs, runner.Job{
+			Name: run.Snapshot().Name,
+			Run: func() error {
+				return mt.RunTask(ctx, cfg, task, run)
+			},
+			Progress:  run,
+			ID:        task.Number,
+			DependsOn: task.DependsOn,
+		})
+	}
+
+	// Execute the tasks and wait for all of them
+	pool := runner.NewPool(cfg.Task.MaxConcurrency)
+	pool.SubmitBatch(jobs, nil)
+	pool.Wait()
+
+	return reportRuns(runs, jsonOutput, func(i int, result *runResultJSON) {
+		result.Number = numbers[i]
+	})
+}
+
+// aidd revise -m <details> <branch>
+func commandRevise(ctx context.Context, cfg *config.Config, branches []string, revisionDetails string, jsonOutput bool) int {
+	if len(branches) != 1 || revisionDetails == "" {
+		fmt.Fprintf(os.Stderr, "please specify one branch and the revision details (-m)\n\n%s", usageText)
+		return exitUsage
+	}
+	branchName := branches[0]
+
+	// Only the branches created by tasks can be revised
+	if _, ok := mt.TaskNumberFromBranch(branchName); !ok {
+		fmt.Fprintf(os.Stderr, "invalid branch %q: specify a task branch (e.g. aidd/task_12)\n\n%s", branchName, usageText)
+		return exitUsage
+	}
+
+	run := mt.NewRun(fmt.Sprintf("Revision %s", branchName))
+	revisionErr := mt.ExecuteAdditionalRevision(ctx, cfg, branchName, revisionDetails, run)
+	if revisionErr != nil {
+		run.Fail(revisionErr)
+	}
+
+	exitCode := reportRuns([]*mt.Run{run}, jsonOutput, func(i int, result *runResultJSON) {
+		result.Branch = branchName
+	})
+	if revisionErr != nil && exitCode == exitOK {
+		exitCode = exitFailure
+	}
+
+	return exitCode
+}
+
+// Print the results of the runs and return the exit code
+func reportRuns(runs []*mt.Run, jsonOutput bool, decorate func(i int, result *runResultJSON)) int {
+	exitCode := exitOK
+	results := []runResultJSON{}
+
+	for i, run := range runs {
+		snapshot := run.Snapshot()
+
+		result := runResultJSON{
+			Name:          snapshot.Name,
+			Status:        snapshot.Status,
+			Workspace:     snapshot.Workspace,
+			TranscriptDir: snapshot.TranscriptDir,
+			PRURL:         snapshot.PRURL,
+			Seconds:       snapshot.Elapsed().Seconds(),
+		}
+		if snapshot.Err != nil {
+			result.Error = snapshot.Err.Error()
+		}
+		decorate(i, &result)
+		results = append(results, result)
+
+		// Cancellation takes precedence over failures in the exit code
+		switch snapshot.Status {
+		case mt.RunStatusSucceeded:
+		case mt.RunStatusCancelled:
+			exitCode = exitCancelled
+		default:
+			if exitCode == exitOK {
+				exitCode = exitFailure
+			}
+		}
+	}
+
+	if jsonOutput {
+		printJSON(results)
+		return exitCode
+	}
+
+	for i, result := range results {
+		fmt.Printf("[%s] %s (%s)\n", result.Status, result.Name, formatElapsed(runs[i].Snapshot().Elapsed()))
+		if result.PRURL != "" {
+			fmt.Printf("  PR: %s\n", result.PRURL)
+		}
+		if result.Error != "" {
+			fmt.Printf("  %s\n", result.Error)
+		}
+	}
+
+	return exitCode
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/tomoyuki65/go-aidd/internal/module/runner"
+	mt "github.com/tomoyuki65/go-aidd/internal/module/task"
+)
+
+// Interval at which the dashboard is refreshed while it is displayed
+const dashboardRefreshInterval = time.Second
+
+// Interval at which the new lines of an attached log are drawn, and how many lines are buffered until then
+const (
+	logRedrawInterval = 100 * time.Millisecond
+	logBufferLines    = 4096
+)
+
+// Application whose screen updates from other goroutines are skipped once it has stopped
+//
+// tview's QueueUpdateDraw blocks forever after Run has returned, which would
+// keep the callbacks of the runs (and so pool.Wait) from returning on quit.
+type uiApp struct {
+	*tview.Application
+	// Closed when Run has returned
+	stopped chan struct{}
+}
+
+func newUIApp(app *tview.Application) *uiApp {
+	return &uiApp{
+		Application: app,
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Run the event loop and mark the app as stopped when it returns
+func (a *uiApp) Run() error {
+	defer close(a.stopped)
+	return a.Application.Run()
+}
+
+// Run f on the event loop and redraw the screen (nothing is done once the app has stopped)
+func (a *uiApp) QueueUpdateDraw(f func()) {
+	select {
+	case <-a.stopped:
+		return
+	default:
+	}
+
+	// The app may stop while the update is queued, so don't wait for it after that
+	done := make(chan struct{})
+	go func() {
+		a.Application.QueueUpdateDraw(f)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-a.stopped:
+	}
+}
+
+// Status color on the dashboard
+func runStatusColor(status string) string {
+	switch status {
+	case mt.RunStatusRunning:
+		return "yellow"
+	case mt.RunStatusSucceeded:
+		return "green"
+	case mt.RunStatusFailed, mt.RunStatusTimedOut, mt.RunStatusStalled, mt.RunStatusLimitExceeded:
+		return "red"
+	case mt.RunStatusCancelled:
+		return "gray"
+	default:
+		return "white"
+	}
+}
+
+// Display the AI output of a run in a scrollable log pane
+//
+// The pane is attached to the run and receives new output while it is
+// displayed. Lines are buffered and drawn in batches so that the AI tool never
+// waits for the screen (lines beyond the buffer are skipped and counted).
+// Detaching (ESC) only closes the pane; the run keeps running and can be
+// attached to again from the dashboard.
+func showRunLog(app *uiApp, pages *tview.Pages, run *mt.Run) {
+	snapshot := run.Snapshot()
+
+	logDescription := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[yellow]Output of the AI tool. Use the arrow keys to scroll, and press ESC to detach from the log.[-]")
+
+	logView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWordWrap(true)
+
+	// Translate ANSI colors of the AI tool into tview color tags
+	logWriter := tview.ANSIWriter(logView)
+	writeLine := func(line string) {
+		fmt.Fprintln(logWriter, tview.Escape(line))
+	}
+
+	// Attach to the run (output received so far is displayed first)
+	pending := make(chan string, logBufferLines)
+	detached := make(chan struct{})
+	var skipped atomic.Int64
+	lines, detach := run.AttachLog(func(line string) {
+		select {
+		case <-detached:
+		case pending <- line:
+		default:
+			skipped.Add(1)
+		}
+	})
+	for _, line := range lines {
+		writeLine(line)
+	}
+	logView.ScrollToEnd()
+
+	// Draw the buffered lines until the pane is detached or the app stops
+	go func() {
+		ticker := time.NewTicker(logRedrawInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-detached:
+				return
+			case <-app.stopped:
+				return
+			case <-ticker.C:
+			}
+
+			var batch []string
+		drain:
+			for {
+				select {
+				case line := <-pending:
+					batch = append(batch, line)
+				default:
+					break drain
+				}
+			}
+			n := skipped.Swap(0)
+			if len(batch) == 0 && n == 0 {
+				continue
+			}
+
+			app.QueueUpdateDraw(func() {
+				if n > 0 {
+					fmt.Fprintf(logView, "[gray](%d lines skipped)[-]\n", n)
+				}
+				for _, line := range batch {
+					writeLine(line)
+				}
+			})
+		}
+	}()
+
+	logView.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			detach()
+			close(detached)
+			pages.RemovePage("run_log")
+		}
+	})
+
+	runLog := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(logDescription, 2, 1, false).
+		AddItem(separator, 1, 1, false).
+		AddItem(logView, 0, 1, true)
+	runLog.SetBorder(true).SetTitle(fmt.Sprintf(" Log: %s ", tview.Escape(snapshot.Name)))
+
+	pages.AddPage("run_log", runLog, true, true)
+	app.SetFocus(logView)
+}
+
+// Format the elapsed time for the dashboard
+func formatElapsed(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// Running tasks dashboard settings
+//
+// Returns the dashboard page and a function that refreshes it with the latest
+// progress of the runs submitted to the pool.
+func newDashboard(app *uiApp, pages *tview.Pages, pool *runner.Pool) (tview.Primitive, func()) {
+	dashboardDescription := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[yellow]Progress of the running tasks (updated every second).\nEnter: attach to the log of the selected run / c: cancel the selected run / ESC: return to the main menu[-]")
+
+	runTable := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+
+	runDetail := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+
+	// Display the steps of the selected run
+	var runs []*mt.Run
+	var snapshots []mt.RunSnapshot
+	showRunDetail := func(row int) {
+		if row < 1 || row > len(snapshots) {
+			runDetail.SetText("")
+			return
+		}
+		snapshot := snapshots[row-1]
+
+		var detail strings.Builder
+		fmt.Fprintf(&detail, "[::b]%s[::-]\n", tview.Escape(snapshot.Name))
+		fmt.Fprintf(&detail, "Workspace: %s\n", tview.Escape(snapshot.Workspace))
+		fmt.Fprintf(&detail, "Transcript: %s\n", tview.Escape(snapshot.TranscriptDir))
+		fmt.Fprintf(&detail, "PR URL: %s\n\n", tview.Escape(snapshot.PRURL))
+		for _, step := range snapshot.Steps {
+			fmt.Fprintf(&detail, "・%-14s %s\n", step.Name, formatElapsed(step.Elapsed()))
+		}
+		if snapshot.Warning != "" {
+			fmt.Fprintf(&detail, "\n[orange]Warning: %s[-]\n", tview.Escape(snapshot.Warning))
+		}
+		if snapshot.Err != nil {
+			fmt.Fprintf(&detail, "\n[red]%s[-]\n", tview.Escape(snapshot.Err.Error()))
+		}
+		runDetail.SetText(detail.String())
+	}
+
+	runTable.SetSelectionChangedFunc(func(row, column int) {
+		showRunDetail(row)
+	})
+	runTable.SetSelectedFunc(func(row, column int) {
+		if row >= 1 && row <= len(runs) {
+			showRunLog(app, pages, runs[row-1])
+		}
+	})
+	// Cancel the selected run with the c key
+	runTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune || event.Rune() != 'c' {
+			return event
+		}
+
+		row, _ := runTable.GetSelection()
+		if row < 1 || row > len(runs) {
+			return nil
+		}
+		run := runs[row-1]
+		snapshot := snapshots[row-1]
+		if snapshot.Status != mt.RunStatusQueued && snapshot.Status != mt.RunStatusRunning {
+			return nil
+		}
+
+		cancelModal := tview.NewModal().
+			SetText(fmt.Sprintf("Do you want to cancel %s ?\n\nThe processes of the run are killed and its changes are not committed.", tview.Escape(snapshot.Name))).
+			AddButtons([]string{"Cancel run", "Back"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				pages.RemovePage("cancel_modal")
+				if buttonLabel == "Cancel run" {
+					run.Cancel()
+				}
+			})
+		pages.AddPage("cancel_modal", cancelModal, true, true)
+
+		return nil
+	})
+	runTable.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			pages.SwitchToPage("main_menu")
+		}
+	})
+
+	refresh := func() {
+		runs = pool.Runs()
+
+		snapshots = snapshots[:0]
+		for _, run := range runs {
+			snapshots = append(snapshots, run.Snapshot())
+		}
+
+		runTable.Clear()
+
+		// Header
+		headers := []string{"Name", "Status", "Step", "Step elapsed", "Total", "Workspace", "PR URL"}
+		for column, header := range headers {
+			runTable.SetCell(0, column, tview.NewTableCell(header).
+				SetTextColor(tcell.ColorYellow).
+				SetSelectable(false))
+		}
+
+		// One row per run
+		for i, snapshot := range snapshots {
+			row := i + 1
+
+			stepName, stepElapsed := "-", "-"
+			if step, ok := snapshot.CurrentStep(); ok {
+				stepName = step.Name
+				stepElapsed = formatElapsed(step.Elapsed())
+			}
+
+			runTable.SetCell(row, 0, tview.NewTableCell(tview.Escape(snapshot.Name)).SetMaxWidth(30))
+			status := fmt.Sprintf("[%s]%s[-]", runStatusColor(snapshot.Status), snapshot.Status)
+			if snapshot.Warning != "" {
+				status = fmt.Sprintf("[orange]%s (!)[-]", snapshot.Status)
+			}
+			runTable.SetCell(row, 1, tview.NewTableCell(status))
+			runTable.SetCell(row, 2, tview.NewTableCell(stepName))
+			runTable.SetCell(row, 3, tview.NewTableCell(stepElapsed))
+			runTable.SetCell(row, 4, tview.NewTableCell(formatElapsed(snapshot.Elapsed())))
+			runTable.SetCell(row, 5, tview.NewTableCell(tview.Escape(snapshot.Workspace)).SetMaxWidth(40))
+			runTable.SetCell(row, 6, tview.NewTableCell(tview.Escape(snapshot.PRURL)))
+		}
+
+		if len(snapshots) == 0 {
+			runTable.SetCell(1, 0, tview.NewTableCell("There are no runs yet !").SetSelectable(false))
+		}
+
+		row, _ := runTable.GetSelection()
+		showRunDetail(row)
+	}
+
+	dashboard := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(dashboardDescription, 2, 1, false).
+		AddItem(separator, 1, 1, false).
+		AddItem(runTable, 12, 1, true).
+		AddItem(separator, 1, 1, false).
+		AddItem(runDetail, 0, 1, false)
+	dashboard.SetBorder(true).SetTitle(" Running tasks dashboard ")
+
+	return dashboard, refresh
+}
@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
 	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/module/runner"
 	mt "github.com/tomoyuki65/go-aidd/internal/module/task"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+
+	// Issue providers and forges (registered in their init functions)
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/container"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/gitea"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/github"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/gitlab"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/jira"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/local"
+	_ "github.com/tomoyuki65/go-aidd/internal/provider/notion"
 )
 
 // Set up common components
@@ -16,12 +32,34 @@ var separator *tview.TextView = tview.NewTextView().
 	SetText("------------------------------------------------------------------------------------------")
 
 // Display task details
-func showTaskDetail(cfg *config.Config, app *tview.Application, pages *tview.Pages, task mt.Task) {
+func showTaskDetail(ctx context.Context, cfg *config.Config, app *uiApp, pages *tview.Pages, pool *runner.Pool, task mt.Task) {
 	description := tview.NewTextView().
 		SetDynamicColors(true).
 		SetText("[yellow]Would you like to run this task ?[-]")
 
-	taskInfoText := fmt.Sprintf("Number: %d\nTitle: %s\n\nBody:\n-----\n%s", task.Number, task.Title, task.Body)
+	taskInfoText := fmt.Sprintf("Number: %s\nTitle: %s\n", task.Number, task.Title)
+
+	// Metadata of tasks (set in the columns of task.md or the front matter of task files)
+	if task.Status != "" {
+		taskInfoText += fmt.Sprintf("Status: %s\n", task.Status)
+	}
+	if task.Priority != "" {
+		taskInfoText += fmt.Sprintf("Priority: %s\n", task.Priority)
+	}
+	if len(task.Labels) > 0 {
+		taskInfoText += fmt.Sprintf("Labels: %s\n", strings.Join(task.Labels, ", "))
+	}
+	if task.AIType != "" || task.AIModel != "" {
+		taskInfoText += fmt.Sprintf("AI: %s\n", strings.TrimSpace(task.AIType+" "+task.AIModel))
+	}
+	if task.BaseBranch != "" {
+		taskInfoText += fmt.Sprintf("Base branch: %s\n", task.BaseBranch)
+	}
+	if len(task.DependsOn) > 0 {
+		taskInfoText += fmt.Sprintf("Depends on: %s\n", strings.Join(task.DependsOn, ", "))
+	}
+
+	taskInfoText += fmt.Sprintf("\nBody:\n-----\n%s", task.Body)
 
 	taskInfo := tview.NewTextView().
 		SetDynamicColors(true).
@@ -35,44 +73,51 @@ func showTaskDetail(cfg *config.Config, app *tview.Application, pages *tview.Pag
 			pages.RemovePage("task_detail")
 		}).
 		AddButton("Run", func() {
-			// Task running modal settings
-			taskRunningModal := tview.NewModal().SetText("Task running......")
-			pages.AddPage("task_running_modal", taskRunningModal, true, true)
-
-			go func() {
-				// Execute Task
-				err := mt.RunTask(cfg, task)
-
+			// Execute the task in the background (the worker pool limits how many tasks run at once)
+			run := mt.NewRun(fmt.Sprintf("Task %s. %s", task.Number, task.Title))
+			pool.SubmitRun(run, func() error {
+				return mt.RunTask(ctx, cfg, task, run)
+			}, func(err error) {
 				// Screen update settings
 				app.QueueUpdateDraw(func() {
-					pages.RemovePage("task_running_modal")
-
 					// Force redraw to fix UI corruption
 					app.Sync()
 
+					// Use a page name per task so results of concurrent tasks don't overwrite each other
+					resultPage := fmt.Sprintf("task_%s_result", task.Number)
+
 					// In case of an error
 					if err != nil {
 						errorModal := tview.NewModal().
-							SetText(fmt.Sprintf("[yellow][::b]An error occurred !![::-]\n\n%v", err)).
+							SetText(fmt.Sprintf("[yellow][::b]An error occurred in task %s !![::-]\n\n%s", task.Number, tview.Escape(err.Error()))).
 							AddButtons([]string{"OK"}).
 							SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-								pages.RemovePage("error")
+								pages.RemovePage(resultPage)
 							})
-						pages.AddPage("error", errorModal, true, true)
+						pages.AddPage(resultPage, errorModal, true, true)
 						return
 					}
 
 					// Success message
 					successModal := tview.NewModal().
-						SetText("Task completed successfully !!").
+						SetText(fmt.Sprintf("Task %s completed successfully !!", task.Number)).
 						AddButtons([]string{"Close"}).
 						SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-							pages.RemovePage("success")
-							pages.RemovePage("task_detail")
+							pages.RemovePage(resultPage)
 						})
-					pages.AddPage("success", successModal, true, true)
+					pages.AddPage(resultPage, successModal, true, true)
 				})
-			}()
+			})
+
+			// Task started modal settings
+			taskStartedModal := tview.NewModal().
+				SetText(fmt.Sprintf("Task %s has been started !!\n\nYou can check its progress on the running tasks dashboard.", task.Number)).
+				AddButtons([]string{"OK"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					pages.RemovePage("task_started_modal")
+					pages.RemovePage("task_detail")
+				})
+			pages.AddPage("task_started_modal", taskStartedModal, true, true)
 		})
 
 	// Set task information height
@@ -100,40 +145,225 @@ func showTaskDetail(cfg *config.Config, app *tview.Application, pages *tview.Pag
 	app.SetFocus(confirmForm)
 }
 
+// Task list item text with its selection state
+func taskItemText(task mt.Task, selected map[string]bool) string {
+	checkBox := "[ ]"
+	if selected[task.Number] {
+		checkBox = "[x]"
+	}
+
+	text := fmt.Sprintf("%s %s. %s", tview.Escape(checkBox), task.Number, task.Title)
+
+	// Status, priority and labels of the task
+	if task.Status != "" {
+		text += fmt.Sprintf(" [%s]%s[-]", taskStatusColor(task.Status), task.Status)
+	}
+	var details []string
+	if task.Priority != "" {
+		details = append(details, "priority: "+task.Priority)
+	}
+	if len(task.Labels) > 0 {
+		details = append(details, strings.Join(task.Labels, ", "))
+	}
+	if len(details) > 0 {
+		text += fmt.Sprintf(" [gray]%s[-]", tview.Escape("("+strings.Join(details, " / ")+")"))
+	}
+
+	return text
+}
+
+// Status color in the task list
+func taskStatusColor(status string) string {
+	switch status {
+	case mt.StatusTodo:
+		return "white"
+	case provider.StatusRunning:
+		return "yellow"
+	case provider.StatusDone:
+		return "green"
+	case provider.StatusFailed:
+		return "red"
+	default:
+		return "white"
+	}
+}
+
+// Submit the selected tasks to the run queue as a batch
+func runSelectedTasks(ctx context.Context, cfg *config.Config, app *uiApp, pool *runner.Pool, pages *tview.Pages, tasks []mt.Task, selected map[string]bool) {
+	// Create a job for each selected task (in task list order)
+	var jobs []runner.Job
+	for _, t := range tasks {
+		task := t
+		if !selected[task.Number] {
+			continue
+		}
+
+		run := mt.NewRun(fmt.Sprintf("Task %s. %s", task.Number, task.Title))
+		jobs = append(jobs, runner.Job{
+			Name: fmt.Sprintf("%s. %s", task.Number, task.Title),
+			Run: func() error {
+				return mt.RunTask(ctx, cfg, task, run)
+			},
+			Progress:  run,
+			ID:        task.Number,
+			DependsOn: task.DependsOn,
+		})
+	}
+
+	if len(jobs) == 0 {
+		errorModal := tview.NewModal().
+			SetText("Please select the tasks to run with the space key !").
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				pages.RemovePage("error")
+			})
+		pages.AddPage("error", errorModal, true, true)
+		return
+	}
+
+	// Execute the batch in the background and report the result of each task once the queue drains
+	pool.SubmitBatch(jobs, func(results []runner.Result) {
+		// Build the batch summary
+		failedCount := 0
+		var summary strings.Builder
+		for _, result := range results {
+			if result.Err != nil {
+				failedCount++
+				fmt.Fprintf(&summary, "[red]✗[-] %s\n    %v\n", tview.Escape(result.Name), tview.Escape(result.Err.Error()))
+				continue
+			}
+			fmt.Fprintf(&summary, "[green]✓[-] %s\n", tview.Escape(result.Name))
+		}
+
+		// Screen update settings
+		app.QueueUpdateDraw(func() {
+			// Force redraw to fix UI corruption
+			app.Sync()
+
+			batchResultModal := tview.NewModal().
+				SetText(fmt.Sprintf("[yellow][::b]Batch finished: %d succeeded, %d failed[::-][-]\n\n%s",
+					len(results)-failedCount, failedCount, summary.String())).
+				AddButtons([]string{"Close"})
+			batchResultPage := fmt.Sprintf("batch_result_%d", time.Now().UnixNano())
+			batchResultModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				pages.RemovePage(batchResultPage)
+			})
+			pages.AddPage(batchResultPage, batchResultModal, true, true)
+		})
+	})
+
+	// Clear the selection now that the tasks are queued
+	clear(selected)
+
+	// Batch queued modal settings
+	batchQueuedModal := tview.NewModal().
+		SetText(fmt.Sprintf("%d tasks have been added to the run queue !!\n\nYou can check their progress on the running tasks dashboard.", len(jobs))).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			pages.RemovePage("batch_queued_modal")
+		})
+	pages.AddPage("batch_queued_modal", batchQueuedModal, true, true)
+}
+
 // Task list display process
-func renderTasks(cfg *config.Config, app *tview.Application, taskList *tview.List, pages *tview.Pages, tasks []mt.Task, currentPage, pageSize *int) {
+func renderTasks(ctx context.Context, cfg *config.Config, app *uiApp, pool *runner.Pool, taskList *tview.List, pages *tview.Pages, tasks []mt.Task, selected map[string]bool, sortOrder *string, currentPage, pageSize *int) {
 	taskList.Clear()
 
+	// Sort the tasks (tasks is kept in file order so that it can be sorted again)
+	listed, err := mt.SortTasks(tasks, *sortOrder)
+	if err != nil {
+		listed = tasks
+	}
+
 	// Calculate the page range
 	start := *currentPage * *pageSize
 	end := start + *pageSize
-	if end > len(tasks) {
-		end = len(tasks)
+	if end > len(listed) {
+		end = len(listed)
 	}
+	pageTasks := listed[start:end]
 
 	// Display the task list for the current page
-	for _, t := range tasks[start:end] {
+	for _, t := range pageTasks {
 		task := t
-		taskList.AddItem(fmt.Sprintf("%d. %s", task.Number, task.Title), "", 0, func() {
+		taskList.AddItem(taskItemText(task, selected), "", 0, func() {
 			// Display task details
-			showTaskDetail(cfg, app, pages, task)
+			showTaskDetail(ctx, cfg, app, pages, pool, task)
 		})
 	}
 
+	// Toggle the selection of the task under the cursor with the space key
+	taskList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune || event.Rune() != ' ' {
+			return event
+		}
+
+		index := taskList.GetCurrentItem()
+		if index < len(pageTasks) {
+			number := pageTasks[index].Number
+			if selected[number] {
+				delete(selected, number)
+			} else {
+				selected[number] = true
+			}
+
+			renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
+			taskList.SetCurrentItem(index)
+		}
+
+		return nil
+	})
+
 	// Set up pagination
-	pagination := fmt.Sprintf("[green]-- page: %d / %d --[-]", *currentPage+1, (len(tasks)-1) / *pageSize + 1)
+	pagination := fmt.Sprintf("[green]-- page: %d / %d --[-]", *currentPage+1, (len(listed)-1) / *pageSize + 1)
 	taskList.AddItem(pagination, "", 0, nil)
 
-	if end < len(tasks) {
+	// Set up batch execution
+	taskList.AddItem("☑ Select all on this page", "", 'a', func() {
+		// Deselect the page if every task on it is already selected
+		allSelected := true
+		for _, task := range pageTasks {
+			if !selected[task.Number] {
+				allSelected = false
+				break
+			}
+		}
+
+		for _, task := range pageTasks {
+			if allSelected {
+				delete(selected, task.Number)
+			} else {
+				selected[task.Number] = true
+			}
+		}
+
+		renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
+		taskList.SetCurrentItem(len(pageTasks) + 1)
+	})
+	taskList.AddItem(fmt.Sprintf("▶ Run selected tasks (%d)", len(selected)), "", 'x', func() {
+		runSelectedTasks(ctx, cfg, app, pool, pages, listed, selected)
+		renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
+	})
+
+	taskList.AddItem(fmt.Sprintf("⇅ Sort by %s", *sortOrder), "", 's', func() {
+		// Switch to the next order and go back to the first page
+		next := (slices.Index(mt.SortOrders, *sortOrder) + 1) % len(mt.SortOrders)
+		*sortOrder = mt.SortOrders[next]
+		*currentPage = 0
+		renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
+		taskList.SetCurrentItem(len(pageTasks) + 3)
+	})
+
+	if end < len(listed) {
 		taskList.AddItem("▶ Next page", "", 'n', func() {
 			*currentPage++
-			renderTasks(cfg, app, taskList, pages, tasks, currentPage, pageSize)
+			renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
 		})
 	}
 	if *currentPage > 0 {
 		taskList.AddItem("◀ Back page", "", 'b', func() {
 			*currentPage--
-			renderTasks(cfg, app, taskList, pages, tasks, currentPage, pageSize)
+			renderTasks(ctx, cfg, app, pool, taskList, pages, tasks, selected, sortOrder, currentPage, pageSize)
 		})
 	}
 
@@ -144,7 +374,7 @@ func renderTasks(cfg *config.Config, app *tview.Application, taskList *tview.Lis
 }
 
 // Completed Task list display process
-func renderCompletedTasks(cfg *config.Config, app *tview.Application, completedTaskList *tview.List, pages *tview.Pages, completedTasks []mt.CompletedTask, currentPage, pageSize *int) {
+func renderCompletedTasks(ctx context.Context, cfg *config.Config, app *uiApp, pool *runner.Pool, completedTaskList *tview.List, pages *tview.Pages, completedTasks []mt.CompletedTask, currentPage, pageSize *int) {
 	completedTaskList.Clear()
 
 	// Calculate the page range
@@ -181,44 +411,51 @@ func renderCompletedTasks(cfg *config.Config, app *tview.Application, completedT
 						return
 					}
 
-					// Executing additional revision modal settings
-					reviseModal := tview.NewModal().SetText("Executing additional revision......")
-					pages.AddPage("revise_modal", reviseModal, true, true)
-
-					go func() {
-						// Execute additional revision process
-						err := mt.ExecuteAdditionalRevision(cfg, completedTask.BranchName, revisionDetails)
-
+					// Execute additional revision process in the background through the worker pool
+					run := mt.NewRun(fmt.Sprintf("Revision %s", completedTask.BranchName))
+					pool.SubmitRun(run, func() error {
+						return mt.ExecuteAdditionalRevision(ctx, cfg, completedTask.BranchName, revisionDetails, run)
+					}, func(err error) {
 						// Screen update settings
 						app.QueueUpdateDraw(func() {
-							pages.RemovePage("revise_modal")
-
 							// Force redraw to fix UI corruption
 							app.Sync()
 
+							// Use a page name per branch so results of concurrent revisions don't overwrite each other
+							resultPage := fmt.Sprintf("revision_%s_result", completedTask.BranchName)
+
 							// In case of an error
 							if err != nil {
 								errorModal := tview.NewModal().
-									SetText(fmt.Sprintf("[yellow][::b]An error occurred !![::-]\n\n%v", err)).
+									SetText(fmt.Sprintf("[yellow][::b]An error occurred in %s !![::-]\n\n%s", completedTask.BranchName, tview.Escape(err.Error()))).
 									AddButtons([]string{"OK"}).
 									SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-										pages.RemovePage("error")
+										pages.RemovePage(resultPage)
 									})
-								pages.AddPage("error", errorModal, true, true)
+								pages.AddPage(resultPage, errorModal, true, true)
 								return
 							}
 
 							// Success message
 							successModal := tview.NewModal().
-								SetText("Additional revision completed successfully !!").
+								SetText(fmt.Sprintf("Additional revision of %s completed successfully !!", completedTask.BranchName)).
 								AddButtons([]string{"Close"}).
 								SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-									pages.RemovePage("success")
-									pages.RemovePage("revision_form")
+									pages.RemovePage(resultPage)
 								})
-							pages.AddPage("success", successModal, true, true)
+							pages.AddPage(resultPage, successModal, true, true)
 						})
-					}()
+					})
+
+					// Revision started modal settings
+					reviseStartedModal := tview.NewModal().
+						SetText("Additional revision has been started !!\n\nYou can check its progress on the running tasks dashboard.").
+						AddButtons([]string{"OK"}).
+						SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+							pages.RemovePage("revise_started_modal")
+							pages.RemovePage("revision_form")
+						})
+					pages.AddPage("revise_started_modal", reviseStartedModal, true, true)
 				})
 
 			revisionDescription := tview.NewTextView().
@@ -249,13 +486,13 @@ func renderCompletedTasks(cfg *config.Config, app *tview.Application, completedT
 	if end < len(completedTasks) {
 		completedTaskList.AddItem("▶ Next page", "", 'n', func() {
 			*currentPage++
-			renderCompletedTasks(cfg, app, completedTaskList, pages, completedTasks, currentPage, pageSize)
+			renderCompletedTasks(ctx, cfg, app, pool, completedTaskList, pages, completedTasks, currentPage, pageSize)
 		})
 	}
 	if *currentPage > 0 {
 		completedTaskList.AddItem("◀ Back page", "", 'b', func() {
 			*currentPage--
-			renderCompletedTasks(cfg, app, completedTaskList, pages, completedTasks, currentPage, pageSize)
+			renderCompletedTasks(ctx, cfg, app, pool, completedTaskList, pages, completedTasks, currentPage, pageSize)
 		})
 	}
 
@@ -266,11 +503,23 @@ func renderCompletedTasks(cfg *config.Config, app *tview.Application, completedT
 }
 
 func main() {
+	// Execute a subcommand without the TUI if one is given (e.g. aidd run 1 2)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Define the worker pool that executes tasks and revisions in parallel
+	pool := runner.NewPool(cfg.Task.MaxConcurrency)
+
+	// Context of all runs (cancelled when the app exits so no AI process is left running)
+	ctx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+
 	// Define the app using tview (mouse enabled)
-	app := tview.NewApplication().EnableMouse(true)
+	app := newUIApp(tview.NewApplication().EnableMouse(true))
 
 	// Define pages
 	pages := tview.NewPages()
@@ -280,10 +529,16 @@ func main() {
 	completedTaskCurrentPage := 0
 	taskPageSize := cfg.Task.ListPageSize
 
+	// Numbers of the tasks selected for batch execution
+	selectedTasks := map[string]bool{}
+
+	// Order of the task list (switched with the s key)
+	taskSortOrder := mt.SortByFile
+
 	// -- Task List Settings --
 	taskDescription := tview.NewTextView().
 		SetDynamicColors(true).
-		SetText("[yellow]Please select the task you want to run.[-]")
+		SetText("[yellow]Please select the task you want to run.\nPress the space key to select multiple tasks and run them all at once.[-]")
 
 	taskSelectList := tview.NewList()
 
@@ -312,6 +567,9 @@ func main() {
 		AddItem(nil, 0, 1, false)
 	completedTaskMenu.SetBorder(true).SetTitle(" Completed Task list menu ")
 
+	// -- Running Tasks Dashboard Settings --
+	dashboard, refreshDashboard := newDashboard(app, pages, pool)
+
 	// -- Main Menu Settings --
 	mainDescription := tview.NewTextView().
 		SetDynamicColors(true).
@@ -361,8 +619,8 @@ func main() {
 			// Initialize the current page of the task list to 0
 			taskCurrentPage = 0
 
-			// Load task information from task.md
-			tasks, err := mt.LoadTaskMd()
+			// Load the tasks (from task.md, or from the provider if it holds them itself)
+			tasks, err := mt.LoadTasks(cfg)
 			if err != nil {
 				errorModal := tview.NewModal().
 					SetText(fmt.Sprintf("[yellow][::b]An error occurred !![::-]\n\n%v", err)).
@@ -374,8 +632,9 @@ func main() {
 				return
 			}
 
-			// Display the task list
-			renderTasks(cfg, app, taskSelectList, pages, tasks, &taskCurrentPage, &taskPageSize)
+			// Display the task list (with no tasks selected)
+			clear(selectedTasks)
+			renderTasks(ctx, cfg, app, pool, taskSelectList, pages, tasks, selectedTasks, &taskSortOrder, &taskCurrentPage, &taskPageSize)
 			pages.SwitchToPage("task_menu")
 		}).
 		AddItem("[::b]・Edit completed task branches[::-]", "", '3', func() {
@@ -396,18 +655,44 @@ func main() {
 			}
 
 			// Display the completed task list
-			renderCompletedTasks(cfg, app, completedTaskSelectList, pages, completedTasks, &completedTaskCurrentPage, &taskPageSize)
+			renderCompletedTasks(ctx, cfg, app, pool, completedTaskSelectList, pages, completedTasks, &completedTaskCurrentPage, &taskPageSize)
 			pages.SwitchToPage("completed_task_menu")
 		}).
+		AddItem("[::b]・Show running tasks dashboard[::-]", "", '4', func() {
+			refreshDashboard()
+			pages.SwitchToPage("dashboard")
+		}).
 		AddItem("Quit", "", 'q', func() {
-			app.Stop()
+			// Confirm before cancelling the runs still in progress
+			inProgress := 0
+			for _, run := range pool.Runs() {
+				status := run.Snapshot().Status
+				if status == mt.RunStatusQueued || status == mt.RunStatusRunning {
+					inProgress++
+				}
+			}
+			if inProgress == 0 {
+				app.Stop()
+				return
+			}
+
+			quitModal := tview.NewModal().
+				SetText(fmt.Sprintf("[yellow][::b]%d runs are still in progress !![::-][-]\n\nQuitting cancels them. Do you want to quit ?", inProgress)).
+				AddButtons([]string{"Quit", "Cancel"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					pages.RemovePage("quit_modal")
+					if buttonLabel == "Quit" {
+						app.Stop()
+					}
+				})
+			pages.AddPage("quit_modal", quitModal, true, true)
 		})
 
 	mainMenu := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(mainDescription, 2, 1, false).
 		AddItem(separator, 1, 1, false).
-		AddItem(mainSelectList, 8, 1, true).
+		AddItem(mainSelectList, 10, 1, true).
 		AddItem(separator, 1, 1, false).
 		AddItem(nil, 0, 1, false)
 	mainMenu.SetBorder(true).SetTitle(" Main menu ")
@@ -416,9 +701,38 @@ func main() {
 	pages.AddPage("main_menu", mainMenu, true, true)
 	pages.AddPage("task_menu", taskMenu, true, false)
 	pages.AddPage("completed_task_menu", completedTaskMenu, true, false)
+	pages.AddPage("dashboard", dashboard, true, false)
+
+	// Refresh the dashboard periodically while it is displayed (until the app stops)
+	tickerDone := make(chan struct{})
+	go func() {
+		defer close(tickerDone)
+
+		ticker := time.NewTicker(dashboardRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-app.stopped:
+				return
+			case <-ticker.C:
+			}
+			app.QueueUpdateDraw(func() {
+				if name, _ := pages.GetFrontPage(); name == "dashboard" {
+					refreshDashboard()
+				}
+			})
+		}
+	}()
 
 	// App startup process
-	if err := app.SetRoot(pages, true).Run(); err != nil {
+	app.SetRoot(pages, true)
+	if err := app.Run(); err != nil {
 		panic(err)
 	}
+	<-tickerDone
+
+	// Stop the runs still in progress and wait until their processes have exited
+	cancelRuns()
+	pool.Wait()
 }
@@ -4,33 +4,150 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
 )
 
+// Settings of the target repository shared by all forges (GitHub, GitLab, ...)
+type RepositoryConfig struct {
+	Repository           string `koanf:"repository"`
+	CloneType            string `koanf:"clone_type"`
+	CloneBranch          string `koanf:"clone_branch"`
+	PushBranchOnComplete bool   `koanf:"push_branch_on_complete"`
+	CreatePrOnComplete   bool   `koanf:"create_pr_on_complete"`
+	PrDraft              bool   `koanf:"pr_draft"`
+}
+
+// Settings of the downloads of task attachments (0 or empty means the default)
+type DownloadConfig struct {
+	Concurrency int           `koanf:"concurrency"`
+	Retries     int           `koanf:"retries"`
+	MaxSize     string        `koanf:"max_size"`
+	Timeout     time.Duration `koanf:"timeout"`
+	// Hosts other than the provider's that attachments are downloaded from
+	Hosts []DownloadHost `koanf:"hosts"`
+}
+
+// Host attachments are downloaded from (download.hosts)
+//
+// Host is a host name, optionally with a leading "*." matching its
+// subdomains. The values of Headers (e.g. Authorization) can refer to
+// environment variables as ${NAME}.
+type DownloadHost struct {
+	Host    string            `koanf:"host"`
+	Headers map[string]string `koanf:"headers"`
+}
+
+// Command line of an AI tool (ai.agents)
+//
+// Command and ModelArgs are argv templates where {prompt}, {model},
+// {workspace} and {prompt_file} are replaced. ModelArgs are appended only when
+// a model is set. ImageArgs are appended for each image attached to the task,
+// with {image} replaced by its path. Stdin is "none" (default) or "prompt" (the
+// prompt is written to the standard input).
+type AgentConfig struct {
+	Command   []string          `koanf:"command"`
+	ModelArgs []string          `koanf:"model_args"`
+	ImageArgs []string          `koanf:"image_args"`
+	Env       map[string]string `koanf:"env"`
+	Stdin     string            `koanf:"stdin"`
+}
+
 type Config struct {
 	Issue struct {
-		Provider string `koanf:"provider"`
-		Label    string `koanf:"label"`
+		Provider          string `koanf:"provider"`
+		Label             string `koanf:"label"`
+		UpdateStatus      bool   `koanf:"update_status"`
+		CommentOnComplete bool   `koanf:"comment_on_complete"`
 	} `koanf:"issue"`
+	// Hosting service of the target repository (default: GitHub)
+	Forge  string `koanf:"forge"`
 	GitHub struct {
-		Repository           string `koanf:"repository"`
-		CloneType            string `koanf:"clone_type"`
-		CloneBranch          string `koanf:"clone_branch"`
-		PushBranchOnComplete bool   `koanf:"push_branch_on_complete"`
-		CreatePrOnComplete   bool   `koanf:"create_pr_on_complete"`
-		PrDraft              bool   `koanf:"pr_draft"`
+		RepositoryConfig `koanf:",squash"`
 	} `koanf:"github"`
+	GitLab struct {
+		RepositoryConfig `koanf:",squash"`
+		BaseURL          string `koanf:"base_url"`
+		Token            string `koanf:"token"`
+	} `koanf:"gitlab"`
+	Gitea struct {
+		RepositoryConfig `koanf:",squash"`
+		BaseURL          string `koanf:"base_url"`
+		Token            string `koanf:"token"`
+	} `koanf:"gitea"`
+	Notion struct {
+		Token          string `koanf:"token"`
+		DatabaseID     string `koanf:"database_id"`
+		FilterProperty string `koanf:"filter_property"`
+		FilterValue    string `koanf:"filter_value"`
+		NumberProperty string `koanf:"number_property"`
+		StatusProperty string `koanf:"status_property"`
+		// Endpoint of the Notion API (e.g. a local stand-in for testing)
+		BaseURL string `koanf:"base_url"`
+	} `koanf:"notion"`
+	Jira struct {
+		BaseURL     string            `koanf:"base_url"`
+		Email       string            `koanf:"email"`
+		Token       string            `koanf:"token"`
+		APIVersion  int               `koanf:"api_version"`
+		JQL         string            `koanf:"jql"`
+		Transitions map[string]string `koanf:"transitions"`
+	} `koanf:"jira"`
+	Local struct {
+		Dir string `koanf:"dir"`
+	} `koanf:"local"`
+	Download  DownloadConfig `koanf:"download"`
+	Container struct {
+		Enabled      bool     `koanf:"enabled"`
+		Runtime      string   `koanf:"runtime"`
+		Image        string   `koanf:"image"`
+		Dockerfile   string   `koanf:"dockerfile"`
+		BuildContext string   `koanf:"build_context"`
+		Env          []string `koanf:"env"`
+		RunArgs      []string `koanf:"run_args"`
+	} `koanf:"container"`
+	Sandbox struct {
+		Enabled        bool     `koanf:"enabled"`
+		ReadWritePaths []string `koanf:"read_write_paths"`
+		ReadOnlyPaths  []string `koanf:"read_only_paths"`
+		DisableNetwork bool     `koanf:"disable_network"`
+	} `koanf:"sandbox"`
+	Limits struct {
+		CPUTime      time.Duration `koanf:"cpu_time"`
+		Memory       string        `koanf:"memory"`
+		MaxProcesses int           `koanf:"max_processes"`
+		Disk         string        `koanf:"disk"`
+	} `koanf:"limits"`
+	// Built-in AI tool (ai.type: aidd) that uses an OpenAI-compatible chat completions API
+	Agent struct {
+		BaseURL         string        `koanf:"base_url"`
+		APIKey          string        `koanf:"api_key"`
+		MaxTurns        int           `koanf:"max_turns"`
+		AllowedCommands []string      `koanf:"allowed_commands"`
+		CommandTimeout  time.Duration `koanf:"command_timeout"`
+		RequestTimeout  time.Duration `koanf:"request_timeout"`
+	} `koanf:"agent"`
 	Task struct {
-		ListPageSize     int  `koanf:"list_page_size"`
-		SkipRunTask      bool `koanf:"skip_run_task"`
-		SkipExecRevision bool `koanf:"skip_exec_revision"`
+		ListPageSize     int           `koanf:"list_page_size"`
+		SkipRunTask      bool          `koanf:"skip_run_task"`
+		SkipExecRevision bool          `koanf:"skip_exec_revision"`
+		MaxConcurrency   int           `koanf:"max_concurrency"`
+		Timeout          time.Duration `koanf:"timeout"`
+		StallWarning     time.Duration `koanf:"stall_warning"`
+		StallTimeout     time.Duration `koanf:"stall_timeout"`
 	} `koanf:"task"`
+	// Templates (text/template) of the prompts passed to the AI tool
+	Prompt struct {
+		Task     string `koanf:"task"`
+		Revision string `koanf:"revision"`
+	} `koanf:"prompt"`
 	AI struct {
-		Type  string `koanf:"type"`
-		Model string `koanf:"model"`
+		Type   string                 `koanf:"type"`
+		Model  string                 `koanf:"model"`
+		Agents map[string]AgentConfig `koanf:"agents"`
 	} `koanf:"ai"`
 }
 
@@ -69,5 +186,10 @@ func LoadConfig() *Config {
 		log.Fatalf("failed to unmarshal config: %v", err)
 	}
 
+	// Tasks of the container provider always run in containers
+	if cfg.Issue.Provider == "container" {
+		cfg.Container.Enabled = true
+	}
+
 	return &cfg
 }
@@ -0,0 +1,188 @@
+// Package agent implements the built-in AI tool (ai.type: aidd), which works
+// on the workspace by calling an OpenAI-compatible chat completions API.
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Name of the subcommand that runs the built-in agent
+const Command = "agent"
+
+// Environment variable of the API key (not passed as an argument so that it isn't logged)
+const APIKeyEnv = "AIDD_AGENT_API_KEY"
+
+// Default settings (agent section of config.yml)
+const (
+	DefaultBaseURL        = "http://localhost:11434/v1"
+	DefaultMaxTurns       = 50
+	DefaultCommandTimeout = 5 * time.Minute
+	DefaultRequestTimeout = 10 * time.Minute
+)
+
+// Instructions given to the model before the prompt of the task
+const systemPrompt = `You are a software engineer working on a git repository (the workspace).
+Complete the task given by the user by reading and editing the files of the workspace with the tools.
+All paths are relative to the root of the workspace. Don't commit; the changes are committed for you.
+When the task is done, reply with a short summary of the changes without calling any tool.`
+
+// Settings of a run of the agent
+type options struct {
+	promptFile     string
+	baseURL        string
+	model          string
+	apiKey         string
+	maxTurns       int
+	allowed        commandList
+	images         commandList
+	commandTimeout time.Duration
+	requestTimeout time.Duration
+}
+
+// Repeatable flag (commands or images)
+type commandList []string
+
+func (l *commandList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commandList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// Entry point of the agent subcommand (the workspace is the current directory)
+func Main(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.StringVar(&opts.promptFile, "prompt-file", "", "file containing the prompt of the task")
+	fs.StringVar(&opts.baseURL, "base-url", DefaultBaseURL, "base URL of the OpenAI-compatible API")
+	fs.StringVar(&opts.model, "model", "", "model to use")
+	fs.IntVar(&opts.maxTurns, "max-turns", DefaultMaxTurns, "maximum number of requests to the model")
+	fs.Var(&opts.allowed, "allow", "command the model can run (repeatable)")
+	fs.Var(&opts.images, "image", "image attached to the prompt (repeatable)")
+	fs.DurationVar(&opts.commandTimeout, "command-timeout", DefaultCommandTimeout, "timeout of a command run by the model")
+	fs.DurationVar(&opts.requestTimeout, "request-timeout", DefaultRequestTimeout, "timeout of a request to the API")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.promptFile == "" {
+		fmt.Fprintln(os.Stderr, "agent: -prompt-file is required")
+		return 2
+	}
+	opts.apiKey = os.Getenv(APIKeyEnv)
+
+	// Stop the conversation when the task is cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "agent: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Converse with the model until it replies without calling a tool
+func run(ctx context.Context, opts options) error {
+	prompt, err := os.ReadFile(opts.promptFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the prompt file: %w", err)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get the workspace: %w", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open the workspace: %w", err)
+	}
+	defer root.Close()
+
+	t := &tools{
+		root:            root,
+		dir:             dir,
+		allowedCommands: opts.allowed,
+		commandTimeout:  opts.commandTimeout,
+	}
+	c := newClient(opts.baseURL, opts.apiKey, opts.requestTimeout)
+
+	task := message{Role: "user", Content: string(prompt)}
+	if len(opts.images) > 0 {
+		task.Parts, err = imageParts(string(prompt), opts.images)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := chatRequest{
+		Model: opts.model,
+		Messages: []message{
+			{Role: "system", Content: systemPrompt},
+			task,
+		},
+		Tools: t.definitions(),
+	}
+
+	for turn := 1; turn <= opts.maxTurns; turn++ {
+		reply, err := c.complete(ctx, req)
+		if err != nil {
+			return err
+		}
+		reply.Role = "assistant"
+		req.Messages = append(req.Messages, reply)
+
+		if text := strings.TrimSpace(reply.Content); text != "" {
+			fmt.Println(text)
+		}
+		if len(reply.ToolCalls) == 0 {
+			return nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			fmt.Printf("[agent] %s %s\n", call.Function.Name, call.Function.Arguments)
+			result := t.call(ctx, call.Function.Name, call.Function.Arguments)
+			if strings.HasPrefix(result, "error: ") {
+				fmt.Println("[agent] " + result)
+			}
+			req.Messages = append(req.Messages, message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return fmt.Errorf("the task was not finished within %d turns (agent.max_turns)", opts.maxTurns)
+}
+
+// Content of the prompt with the images embedded as data URLs
+func imageParts(prompt string, images []string) ([]contentPart, error) {
+	parts := []contentPart{{Type: "text", Text: prompt}}
+	for _, image := range images {
+		data, err := os.ReadFile(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+
+		url := "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: url}})
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Stand-in of an OpenAI-compatible API that replies with the messages returned by reply
+type stubAPI struct {
+	t     *testing.T
+	reply func(turn int, req chatRequest) (message, int)
+
+	mu       sync.Mutex
+	requests []chatRequest
+}
+
+func (s *stubAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+		s.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+		s.t.Errorf("Authorization = %q, want Bearer test-key", got)
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.t.Errorf("failed to decode request: %v", err)
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	turn := len(s.requests)
+	s.mu.Unlock()
+
+	msg, status := s.reply(turn, req)
+	if status != http.StatusOK {
+		http.Error(w, `{"error":{"message":"model not found"}}`, status)
+		return
+	}
+
+	var resp chatResponse
+	resp.Choices = append(resp.Choices, struct {
+		Message      message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	}{Message: msg, FinishReason: "stop"})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Run the agent in a temporary workspace against the stub and return the workspace and the error
+func runAgent(t *testing.T, stub *stubAPI, maxTurns int) (string, error) {
+	t.Helper()
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	workspace := t.TempDir()
+	promptFile := filepath.Join(t.TempDir(), "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("Create hello.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(workspace)
+
+	err := run(context.Background(), options{
+		promptFile:     promptFile,
+		baseURL:        server.URL + "/v1/",
+		model:          "test-model",
+		apiKey:         "test-key",
+		maxTurns:       maxTurns,
+		commandTimeout: 10 * time.Second,
+		requestTimeout: 10 * time.Second,
+	})
+
+	return workspace, err
+}
+
+// Reply that calls a tool
+func toolCallMessage(id, name, arguments string) message {
+	call := toolCall{ID: id, Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = arguments
+	return message{Role: "assistant", ToolCalls: []toolCall{call}}
+}
+
+func TestRunToolCallRoundTrip(t *testing.T) {
+	stub := &stubAPI{t: t, reply: func(turn int, req chatRequest) (message, int) {
+		switch turn {
+		case 1:
+			return toolCallMessage("call_1", "write_file", `{"path":"dir/hello.txt","content":"hello"}`), http.StatusOK
+		default:
+			return message{Role: "assistant", Content: "Created hello.txt"}, http.StatusOK
+		}
+	}}
+
+	workspace, err := runAgent(t, stub, 5)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, "dir", "hello.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("hello.txt = %q (%v), want hello", data, err)
+	}
+
+	if len(stub.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(stub.requests))
+	}
+
+	first := stub.requests[0]
+	if first.Model != "test-model" || len(first.Tools) == 0 {
+		t.Errorf("first request = model %q with %d tools, want test-model with the tools", first.Model, len(first.Tools))
+	}
+	if len(first.Messages) != 2 || first.Messages[0].Role != "system" || first.Messages[1].Content != "Create hello.txt" {
+		t.Errorf("first messages = %+v, want the system prompt and the task", first.Messages)
+	}
+
+	// The result of the tool call is sent back with the id of the call
+	second := stub.requests[1]
+	last := second.Messages[len(second.Messages)-1]
+	if last.Role != "tool" || last.ToolCallID != "call_1" || !strings.Contains(last.Content, "wrote 5 bytes to dir/hello.txt") {
+		t.Errorf("tool result = %+v", last)
+	}
+	if assistant := second.Messages[len(second.Messages)-2]; assistant.Role != "assistant" || len(assistant.ToolCalls) != 1 {
+		t.Errorf("assistant message = %+v, want the tool call", assistant)
+	}
+}
+
+func TestRunMaxTurns(t *testing.T) {
+	stub := &stubAPI{t: t, reply: func(turn int, req chatRequest) (message, int) {
+		return toolCallMessage("call", "list_dir", `{"path":"."}`), http.StatusOK
+	}}
+
+	_, err := runAgent(t, stub, 3)
+	if err == nil || !strings.Contains(err.Error(), "within 3 turns") {
+		t.Fatalf("run error = %v, want the max turns error", err)
+	}
+	if len(stub.requests) != 3 {
+		t.Errorf("got %d requests, want 3", len(stub.requests))
+	}
+}
+
+func TestRunAPIError(t *testing.T) {
+	stub := &stubAPI{t: t, reply: func(turn int, req chatRequest) (message, int) {
+		return message{}, http.StatusNotFound
+	}}
+
+	_, err := runAgent(t, stub, 3)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "model not found") {
+		t.Fatalf("run error = %v, want the 404 error with its message", err)
+	}
+	if len(stub.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(stub.requests))
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Message of a chat completion
+type message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// Content with images (sent instead of Content when set)
+	Parts []contentPart `json:"-"`
+}
+
+// Part of the content of a message with images
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// Send the parts as the content when the message has images
+func (m message) MarshalJSON() ([]byte, error) {
+	type plain message
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	return json.Marshal(struct {
+		plain
+		Content []contentPart `json:"content"`
+	}{plain(m), m.Parts})
+}
+
+// Call of a tool requested by the model
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Definition of a tool passed to the model
+type toolDefinition struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatRequest struct {
+	Model    string           `json:"model"`
+	Messages []message        `json:"messages"`
+	Tools    []toolDefinition `json:"tools,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// Client of an OpenAI-compatible chat completions API (OpenAI, llama.cpp, Ollama, vLLM etc.)
+type client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func newClient(baseURL, apiKey string, timeout time.Duration) *client {
+	return &client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Request the next message of the conversation
+func (c *client) complete(ctx context.Context, in chatRequest) (message, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := c.baseURL + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return message{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return message{}, fmt.Errorf("POST %s: unexpected status code %d %s: %s", endpoint, resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(data)))
+	}
+
+	var out chatResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return message{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return message{}, fmt.Errorf("POST %s: no choices in response", endpoint)
+	}
+
+	return out.Choices[0].Message, nil
+}
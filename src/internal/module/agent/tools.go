@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Maximum size of a file or command output returned to the model
+const maxToolOutput = 64 * 1024
+
+// Tools the model can use, all scoped to the workspace
+//
+// Paths are resolved through an os.Root, so they can't escape the workspace
+// (e.g. with ".." or symbolic links).
+type tools struct {
+	root            *os.Root
+	dir             string
+	allowedCommands []string
+	commandTimeout  time.Duration
+}
+
+// Definitions of the tools passed to the model
+func (t *tools) definitions() []toolDefinition {
+	commands := "No commands are allowed."
+	if len(t.allowedCommands) > 0 {
+		commands = "Allowed commands: " + strings.Join(t.allowedCommands, ", ") + "."
+	}
+
+	return []toolDefinition{
+		newToolDefinition("read_file", "Read a file of the workspace.", map[string]any{
+			"path": stringParam("Path of the file relative to the workspace"),
+		}),
+		newToolDefinition("write_file", "Create or overwrite a file of the workspace (parent directories are created).", map[string]any{
+			"path":    stringParam("Path of the file relative to the workspace"),
+			"content": stringParam("New content of the file"),
+		}),
+		newToolDefinition("list_dir", "List the entries of a directory of the workspace (directories end with /).", map[string]any{
+			"path": stringParam("Path of the directory relative to the workspace (\".\" for the root)"),
+		}),
+		newToolDefinition("run_command", "Run a command in the root of the workspace and get its output. "+commands, map[string]any{
+			"command": stringParam("Name of the command"),
+			"args": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Arguments of the command",
+			},
+		}),
+	}
+}
+
+func newToolDefinition(name, description string, properties map[string]any) toolDefinition {
+	var d toolDefinition
+	d.Type = "function"
+	d.Function.Name = name
+	d.Function.Description = description
+
+	required := make([]string, 0, len(properties))
+	for p := range properties {
+		if p != "args" {
+			required = append(required, p)
+		}
+	}
+	slices.Sort(required)
+	d.Function.Parameters = map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+
+	return d
+}
+
+func stringParam(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// Execute a tool call and return the result passed back to the model
+//
+// Errors are returned as the result so that the model can correct itself.
+func (t *tools) call(ctx context.Context, name, arguments string) string {
+	var args struct {
+		Path    string   `json:"path"`
+		Content string   `json:"content"`
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Sprintf("error: invalid arguments: %v", err)
+	}
+
+	var result string
+	var err error
+	switch name {
+	case "read_file":
+		result, err = t.readFile(args.Path)
+	case "write_file":
+		result, err = t.writeFile(args.Path, args.Content)
+	case "list_dir":
+		result, err = t.listDir(args.Path)
+	case "run_command":
+		result, err = t.runCommand(ctx, args.Command, args.Args)
+	default:
+		err = fmt.Errorf("unknown tool %q", name)
+	}
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return result
+}
+
+// Convert a path given by the model to a path relative to the workspace
+func relPath(p string) (string, error) {
+	if p == "" {
+		p = "."
+	}
+	p = filepath.Clean(filepath.FromSlash(p))
+	if filepath.IsAbs(p) {
+		return "", fmt.Errorf("%s: use a path relative to the workspace", p)
+	}
+
+	return p, nil
+}
+
+func (t *tools) readFile(p string) (string, error) {
+	rel, err := relPath(p)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := t.root.ReadFile(rel)
+	if err != nil {
+		return "", err
+	}
+
+	return truncate(string(data)), nil
+}
+
+func (t *tools) writeFile(p, content string) (string, error) {
+	rel, err := relPath(p)
+	if err != nil {
+		return "", err
+	}
+
+	// Don't let the model change the hooks or config used by git
+	if first, _, _ := strings.Cut(filepath.ToSlash(rel), "/"); first == ".git" {
+		return "", errors.New(".git can't be written")
+	}
+
+	if dir := filepath.Dir(rel); dir != "." {
+		if err := t.root.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	if err := t.root.WriteFile(rel, []byte(content), 0644); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), filepath.ToSlash(rel)), nil
+}
+
+func (t *tools) listDir(p string) (string, error) {
+	rel, err := relPath(p)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := fs.ReadDir(t.root.FS(), filepath.ToSlash(rel))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.Name())
+		if e.IsDir() {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+
+	return truncate(b.String()), nil
+}
+
+func (t *tools) runCommand(ctx context.Context, command string, args []string) (string, error) {
+	if !slices.Contains(t.allowedCommands, command) {
+		return "", fmt.Errorf("command %q is not allowed", command)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = t.dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	runErr := cmd.Run()
+
+	result := truncate(out.String())
+	switch {
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("timed out after %s\n%s", t.commandTimeout, result)
+	case runErr != nil:
+		return fmt.Sprintf("%v\n%s", runErr, result), nil
+	}
+
+	return "exit status 0\n" + result, nil
+}
+
+// Shorten a tool result that is too large for the context of the model
+func truncate(s string) string {
+	if len(s) <= maxToolOutput {
+		return s
+	}
+
+	return s[:maxToolOutput] + fmt.Sprintf("\n... (truncated, %d bytes in total)", len(s))
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create tools for a temporary workspace next to a file outside of it
+func newTestTools(t *testing.T, allowed ...string) (*tools, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace")
+	if err := os.MkdirAll(filepath.Join(workspace, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(base, "secret.txt")
+	if err := os.WriteFile(secret, []byte("TOP SECRET"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := os.OpenRoot(workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+
+	return &tools{
+		root:            root,
+		dir:             workspace,
+		allowedCommands: allowed,
+		commandTimeout:  10 * time.Second,
+	}, secret
+}
+
+func TestToolsReadFile(t *testing.T) {
+	tl, _ := newTestTools(t)
+
+	if got := tl.call(context.Background(), "read_file", `{"path":"main.go"}`); got != "package main\n" {
+		t.Errorf("read_file main.go = %q", got)
+	}
+}
+
+func TestToolsPathConfinement(t *testing.T) {
+	tl, secret := newTestTools(t)
+
+	// A symbolic link in the workspace pointing outside of it
+	if err := os.Symlink(secret, filepath.Join(tl.dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(secret), filepath.Join(tl.dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		tool      string
+		arguments string
+	}{
+		{name: "read parent", tool: "read_file", arguments: `{"path":"../secret.txt"}`},
+		{name: "read nested parent", tool: "read_file", arguments: `{"path":"sub/../../secret.txt"}`},
+		{name: "read absolute", tool: "read_file", arguments: `{"path":"` + filepath.ToSlash(secret) + `"}`},
+		{name: "read symlink", tool: "read_file", arguments: `{"path":"link.txt"}`},
+		{name: "write parent", tool: "write_file", arguments: `{"path":"../escaped.txt","content":"x"}`},
+		{name: "write absolute", tool: "write_file", arguments: `{"path":"` + filepath.ToSlash(filepath.Join(filepath.Dir(secret), "escaped.txt")) + `","content":"x"}`},
+		{name: "write through symlink", tool: "write_file", arguments: `{"path":"linkdir/escaped.txt","content":"x"}`},
+		{name: "write git", tool: "write_file", arguments: `{"path":".git/hooks/pre-commit","content":"x"}`},
+		{name: "list parent", tool: "list_dir", arguments: `{"path":".."}`},
+		{name: "list symlink", tool: "list_dir", arguments: `{"path":"linkdir"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tl.call(context.Background(), tt.tool, tt.arguments)
+			if !strings.HasPrefix(got, "error: ") {
+				t.Errorf("%s %s = %q, want an error", tt.tool, tt.arguments, got)
+			}
+			if strings.Contains(got, "TOP SECRET") {
+				t.Errorf("%s %s leaked the file: %q", tt.tool, tt.arguments, got)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(secret), "escaped.txt")); err == nil {
+		t.Error("a file was written outside of the workspace")
+	}
+	if _, err := os.Stat(filepath.Join(tl.dir, ".git", "hooks", "pre-commit")); err == nil {
+		t.Error("a file was written in .git")
+	}
+}
+
+func TestToolsRunCommand(t *testing.T) {
+	tl, _ := newTestTools(t, "go")
+
+	// Commands that are not allowed are not run
+	for _, arguments := range []string{
+		`{"command":"sh","args":["-c","touch ran"]}`,
+		`{"command":"/bin/sh","args":["-c","touch ran"]}`,
+		`{"command":"go ","args":["version"]}`,
+	} {
+		got := tl.call(context.Background(), "run_command", arguments)
+		if !strings.HasPrefix(got, "error: ") || !strings.Contains(got, "is not allowed") {
+			t.Errorf("run_command %s = %q, want a not allowed error", arguments, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tl.dir, "ran")); err == nil {
+		t.Error("a command that is not allowed was run")
+	}
+
+	// Allowed commands run in the workspace
+	got := tl.call(context.Background(), "run_command", `{"command":"go","args":["env","GOMOD"]}`)
+	if !strings.HasPrefix(got, "exit status 0\n") {
+		t.Errorf("run_command go env = %q, want exit status 0", got)
+	}
+}
@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tomoyuki65/go-aidd/internal/module/task"
+)
+
+// Number of finished runs kept for the dashboard (the oldest are removed first)
+const maxFinishedRuns = 100
+
+// Job submitted to the pool as part of a batch
+type Job struct {
+	Name string
+	Run  func() error
+	// ID of the job in the batch (e.g. the task number) and the IDs of the
+	// jobs of the batch that must succeed before it starts (optional)
+	ID        string
+	DependsOn []string
+	// Progress of the job shown on the dashboard (optional)
+	Progress *task.Run
+}
+
+// Result of a job in a batch
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Worker pool that limits how many jobs (tasks or revisions) run at the same time
+type Pool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	runs []*task.Run
+}
+
+// Create a worker pool that runs at most maxConcurrency jobs at once
+func NewPool(maxConcurrency int) *Pool {
+	// Fall back to sequential execution when the limit is not set
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	return &Pool{
+		slots: make(chan struct{}, maxConcurrency),
+	}
+}
+
+// Submit a job without blocking the caller
+//
+// The job starts as soon as a worker slot is free, and done (if not nil) is
+// called with its result after it finishes.
+func (p *Pool) Submit(job func() error, done func(err error)) {
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		// Wait for a free worker slot
+		p.slots <- struct{}{}
+		err := job()
+		<-p.slots
+
+		if done != nil {
+			done(err)
+		}
+	}()
+}
+
+// Submit a batch of jobs to the run queue without blocking the caller
+//
+// The jobs are executed by the pool like any other job, and done (if not nil)
+// is called once with the results, in submission order, after every job of
+// the batch has finished. A job with dependencies in the batch waits for them
+// (without taking a worker slot) and fails without running if one of them
+// fails or if they depend on each other.
+func (p *Pool) SubmitBatch(jobs []Job, done func(results []Result)) {
+	if len(jobs) == 0 {
+		if done != nil {
+			done(nil)
+		}
+		return
+	}
+
+	// Dependencies of each job within the batch
+	byID := map[string]int{}
+	for i, job := range jobs {
+		if job.ID != "" {
+			byID[job.ID] = i
+		}
+	}
+	deps := make([][]int, len(jobs))
+	for i, job := range jobs {
+		for _, id := range job.DependsOn {
+			if d, ok := byID[id]; ok {
+				deps[i] = append(deps[i], d)
+			}
+		}
+	}
+	cyclic := cyclicJobs(deps)
+
+	var mu sync.Mutex
+	results := make([]Result, len(jobs))
+	finished := make([]chan struct{}, len(jobs))
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+	remaining := len(jobs)
+
+	record := func(i int, err error) {
+		mu.Lock()
+		results[i] = Result{Name: jobs[i].Name, Err: err}
+		close(finished[i])
+		remaining--
+		allFinished := remaining == 0
+		mu.Unlock()
+
+		if allFinished && done != nil {
+			done(results)
+		}
+	}
+
+	for i, job := range jobs {
+		if job.Progress != nil {
+			p.track(job.Progress)
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+
+			// Wait for the dependencies before taking a worker slot
+			var err error
+			if cyclic[i] {
+				err = fmt.Errorf("not run because of a circular dependency in the batch")
+			} else {
+				for _, d := range deps[i] {
+					<-finished[d]
+					if results[d].Err != nil && err == nil {
+						err = fmt.Errorf("not run because %s failed", jobs[d].Name)
+					}
+				}
+			}
+			if err != nil {
+				if job.Progress != nil {
+					job.Progress.Fail(err)
+				}
+				record(i, err)
+				return
+			}
+
+			p.Submit(job.Run, func(err error) {
+				record(i, err)
+			})
+		}()
+	}
+}
+
+// Jobs that depend on themselves through the dependencies (they can never start)
+func cyclicJobs(deps [][]int) []bool {
+	cyclic := make([]bool, len(deps))
+	for i := range deps {
+		seen := make([]bool, len(deps))
+		stack := append([]int(nil), deps[i]...)
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if n == i {
+				cyclic[i] = true
+				break
+			}
+			if !seen[n] {
+				seen[n] = true
+				stack = append(stack, deps[n]...)
+			}
+		}
+	}
+
+	return cyclic
+}
+
+// Submit a job together with its progress so that it shows on the dashboard
+func (p *Pool) SubmitRun(run *task.Run, job func() error, done func(err error)) {
+	p.track(run)
+	p.Submit(job, done)
+}
+
+// Get the runs submitted to the pool (in submission order)
+//
+// Runs in progress are always included, finished runs only up to maxFinishedRuns.
+func (p *Pool) Runs() []*task.Run {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*task.Run(nil), p.runs...)
+}
+
+// Register a run so that it shows on the dashboard
+func (p *Pool) track(run *task.Run) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.runs = append(p.runs, run)
+	p.prune()
+}
+
+// Remove the oldest finished runs beyond maxFinishedRuns (the caller must hold the lock)
+func (p *Pool) prune() {
+	finished := 0
+	for _, run := range p.runs {
+		if !run.Snapshot().FinishedAt.IsZero() {
+			finished++
+		}
+	}
+
+	excess := finished - maxFinishedRuns
+	if excess <= 0 {
+		return
+	}
+
+	kept := p.runs[:0]
+	for _, run := range p.runs {
+		if excess > 0 && !run.Snapshot().FinishedAt.IsZero() {
+			excess--
+			continue
+		}
+		kept = append(kept, run)
+	}
+	clear(p.runs[len(kept):])
+	p.runs = kept
+}
+
+// Wait until all submitted jobs have finished
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,200 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	ma "github.com/tomoyuki65/go-aidd/internal/module/agent"
+)
+
+// Stdin modes of an AI tool
+const (
+	agentStdinNone   = "none"
+	agentStdinPrompt = "prompt"
+)
+
+// Path of the prompt file in the repository (inside .git so that it is never committed)
+const agentPromptFile = ".git/aidd/prompt.md"
+
+// AI tools that can be used without settings (ai.agents overrides them or adds others)
+//
+// Gemini CLI and Claude Code print nothing until they finish in text mode, so
+// their progress is streamed as JSON lines to keep the stall detection fed.
+var builtinAgents = map[string]config.AgentConfig{
+	"Gemini CLI": {
+		Command:   []string{"gemini", "-p", "{prompt}", "-y", "--output-format", "stream-json"},
+		ModelArgs: []string{"-m", "{model}"},
+	},
+	"Claude Code": {
+		Command:   []string{"claude", "-p", "{prompt}", "--dangerously-skip-permissions", "--output-format", "stream-json", "--verbose"},
+		ModelArgs: []string{"--model", "{model}"},
+	},
+	"Codex": {
+		Command:   []string{"codex", "exec", "--full-auto", "{prompt}"},
+		ModelArgs: []string{"--model", "{model}"},
+		ImageArgs: []string{"--image", "{image}"},
+	},
+	"GitHub Copilot CLI": {
+		Command:   []string{"copilot", "-p", "{prompt}", "--allow-all-tools"},
+		ModelArgs: []string{"--model", "{model}"},
+	},
+}
+
+// AI type of the built-in agent, which is the aidd binary itself
+const builtinAgentType = "aidd"
+
+// Get the AI tool of the given ai.type from ai.agents or the built-in ones
+func lookupAgent(cfg *config.Config, name string) (config.AgentConfig, error) {
+	agent, ok := cfg.AI.Agents[name]
+	if !ok && name == builtinAgentType {
+		return builtinAgent(cfg)
+	}
+	if !ok {
+		agent, ok = builtinAgents[name]
+	}
+	if !ok {
+		return config.AgentConfig{}, fmt.Errorf("unsupported AI type %q is set (add it to ai.agents)", name)
+	}
+
+	if len(agent.Command) == 0 {
+		return config.AgentConfig{}, fmt.Errorf("command of AI type %q is not set", name)
+	}
+	switch agent.Stdin {
+	case "", agentStdinNone, agentStdinPrompt:
+	default:
+		return config.AgentConfig{}, fmt.Errorf("invalid stdin %q of AI type %q (none or prompt)", agent.Stdin, name)
+	}
+
+	return agent, nil
+}
+
+// Command line of the built-in agent built from the agent settings
+func builtinAgent(cfg *config.Config) (config.AgentConfig, error) {
+	// The binary on the host is not available inside the container
+	if cfg.Container.Enabled {
+		return config.AgentConfig{}, fmt.Errorf("AI type %q can't be used with container.enabled", builtinAgentType)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return config.AgentConfig{}, fmt.Errorf("failed to get the path of the executable: %w", err)
+	}
+
+	command := []string{exe, ma.Command, "--prompt-file", "{prompt_file}"}
+	if cfg.Agent.BaseURL != "" {
+		command = append(command, "--base-url", cfg.Agent.BaseURL)
+	}
+	if cfg.Agent.MaxTurns > 0 {
+		command = append(command, "--max-turns", strconv.Itoa(cfg.Agent.MaxTurns))
+	}
+	if cfg.Agent.CommandTimeout > 0 {
+		command = append(command, "--command-timeout", cfg.Agent.CommandTimeout.String())
+	}
+	if cfg.Agent.RequestTimeout > 0 {
+		command = append(command, "--request-timeout", cfg.Agent.RequestTimeout.String())
+	}
+	for _, c := range cfg.Agent.AllowedCommands {
+		command = append(command, "--allow", c)
+	}
+
+	// The API key is passed in the environment so that it doesn't appear in the logs
+	apiKey := cfg.Agent.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	var env map[string]string
+	if apiKey != "" {
+		env = map[string]string{ma.APIKeyEnv: apiKey}
+	}
+
+	return config.AgentConfig{
+		Command:   command,
+		ModelArgs: []string{"--model", "{model}"},
+		ImageArgs: []string{"--image", "{image}"},
+		Env:       env,
+	}, nil
+}
+
+// Names of the environment variables set for the AI tool, sorted
+func agentEnvNames(agent config.AgentConfig) []string {
+	names := make([]string, 0, len(agent.Env))
+	for name := range agent.Env {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Create commands for AI processing (the AI tool runs inside repoDir)
+//
+// The command line is built from the template of the AI tool set in ai.type.
+// The prompt is also written to a file in .git of the repository for tools
+// that read it from a file ({prompt_file}). images are paths relative to
+// repoDir passed to tools that accept them.
+func createCmdForAiProcessing(ctx context.Context, cfg *config.Config, repoDir, prompt string, images []string) (*exec.Cmd, error) {
+	agent, err := lookupAgent(cfg, cfg.AI.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	promptFile := filepath.Join(repoDir, filepath.FromSlash(agentPromptFile))
+	if err := os.MkdirAll(filepath.Dir(promptFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory of the prompt file: %w", err)
+	}
+	if err := os.WriteFile(promptFile, []byte(prompt), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write the prompt file: %w", err)
+	}
+
+	// Paths as seen by the AI tool, which runs on the volume when container.enabled is set
+	workspace := repoDir
+	if cfg.Container.Enabled {
+		workspace = containerWorkspace
+		promptFile = path.Join(containerWorkspace, agentPromptFile)
+	}
+
+	replacer := strings.NewReplacer(
+		"{prompt}", prompt,
+		"{model}", cfg.AI.Model,
+		"{workspace}", workspace,
+		"{prompt_file}", promptFile,
+	)
+
+	args := slices.Clone(agent.Command)
+	if cfg.AI.Model != "" {
+		args = append(args, agent.ModelArgs...)
+	}
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	for _, image := range images {
+		imagePath := filepath.Join(workspace, filepath.FromSlash(image))
+		if cfg.Container.Enabled {
+			imagePath = path.Join(workspace, image)
+		}
+		for _, arg := range agent.ImageArgs {
+			args = append(args, strings.ReplaceAll(replacer.Replace(arg), "{image}", imagePath))
+		}
+	}
+	cmd := newCmd(ctx, repoDir, args[0], args[1:]...)
+
+	if len(agent.Env) > 0 {
+		cmd.Env = os.Environ()
+		for _, name := range agentEnvNames(agent) {
+			cmd.Env = append(cmd.Env, name+"="+replacer.Replace(agent.Env[name]))
+		}
+	}
+	if agent.Stdin == agentStdinPrompt {
+		cmd.Stdin = strings.NewReader(prompt)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,177 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Directory in the workspace the attachments of a task are copied to (excluded from git)
+const attachmentsDir = ".aidd/attachments"
+
+// Regex to extract the targets of links and images in task bodies
+// (e.g. ![screenshot](images/issue_1/img_1.png) or <img src="images/issue_1/img_1.png">)
+var attachmentRefRegex = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)|<img\s[^>]*?\bsrc=["']([^"']+)["']`)
+
+// Extensions of the attachments passed to AI tools that accept images (image_args)
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// Directory the attachments of a task are downloaded to, relative to the directory of task.md
+// (e.g. images/issue_1)
+func downloadedAttachmentsDir(task Task) string {
+	return filepath.Join(task.Dir, "images", fmt.Sprintf("issue_%s", task.Number))
+}
+
+// Copy the downloaded attachments referenced in the task body into the workspace
+//
+// The downloaded attachments (e.g. images/issue_1/img_1.png, relative to the
+// directory of task.md) don't exist in the cloned repository, so they are
+// copied to attachmentsDir and the body is rewritten to point to the copies.
+// Only files inside the downloaded attachments directory of the task are
+// copied, so a body can't hand other files of the host to the AI tool.
+// The paths of the copies (relative to repoDir) are returned.
+func stageAttachments(task Task, repoDir string) (string, []string, error) {
+	// Nothing to stage if no attachments were downloaded for the task
+	root, err := os.OpenRoot(downloadedAttachmentsDir(task))
+	if err != nil {
+		return task.Body, nil, nil
+	}
+	defer root.Close()
+
+	var staged []string
+	copied := map[string]string{}
+	var stageErr error
+
+	body := attachmentRefRegex.ReplaceAllStringFunc(task.Body, func(match string) string {
+		m := attachmentRefRegex.FindStringSubmatch(match)
+		ref := m[1] + m[2]
+
+		src, ok := localAttachment(root, task.Dir, ref)
+		if !ok || stageErr != nil {
+			return match
+		}
+
+		dest, ok := copied[src]
+		if !ok {
+			dest = path.Join(attachmentsDir, uniqueName(copied, path.Base(filepath.ToSlash(src))))
+			if err := copyFile(root, src, filepath.Join(repoDir, filepath.FromSlash(dest))); err != nil {
+				stageErr = fmt.Errorf("failed to copy attachment %s: %w", ref, err)
+				return match
+			}
+			copied[src] = dest
+			staged = append(staged, dest)
+		}
+
+		return strings.Replace(match, ref, dest, 1)
+	})
+	if stageErr != nil {
+		return "", nil, stageErr
+	}
+
+	if len(staged) > 0 {
+		if err := excludeFromGit(repoDir, "/"+attachmentsDir+"/"); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return body, staged, nil
+}
+
+// Resolve a reference in the body (relative to dir) to a file in root
+//
+// The path relative to root is returned. URLs, anchors and paths outside root
+// are ignored, and root keeps symbolic links from escaping it.
+func localAttachment(root *os.Root, dir, ref string) (string, bool) {
+	if strings.Contains(ref, ":") || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "/") {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(root.Name(), filepath.Join(dir, filepath.FromSlash(ref)))
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+
+	info, err := root.Stat(rel)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	return rel, true
+}
+
+// File name that is not used by the attachments copied so far (e.g. 2_img_1.png)
+func uniqueName(copied map[string]string, name string) string {
+	used := map[string]bool{}
+	for _, dest := range copied {
+		used[path.Base(dest)] = true
+	}
+
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = strconv.Itoa(i) + "_" + name
+	}
+
+	return unique
+}
+
+// Copy a file in root to dest
+func copyFile(root *os.Root, src, dest string) error {
+	data, err := root.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(dest, data, 0644)
+}
+
+// Add a pattern to .git/info/exclude so that "git add -A" never commits the files
+func excludeFromGit(repoDir, pattern string) error {
+	excludeFile := filepath.Join(repoDir, ".git", "info", "exclude")
+	content, err := os.ReadFile(excludeFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", excludeFile, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(excludeFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory of %s: %w", excludeFile, err)
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	content = append(content, pattern+"\n"...)
+	if err := os.WriteFile(excludeFile, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", excludeFile, err)
+	}
+
+	return nil
+}
+
+// Attachments that are images, for AI tools that accept them (image_args)
+func imageAttachments(attachments []string) []string {
+	var images []string
+	for _, a := range attachments {
+		if imageExtensions[strings.ToLower(path.Ext(a))] {
+			images = append(images, a)
+		}
+	}
+
+	return images
+}
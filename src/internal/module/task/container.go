@@ -0,0 +1,280 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+)
+
+// Defaults of the container settings
+const (
+	defaultContainerRuntime      = "docker"
+	defaultContainerDockerfile   = "docker/local/go/Dockerfile"
+	defaultContainerBuildContext = "."
+	defaultContainerImage        = "aidd-runner:latest"
+)
+
+// Directory of the repository inside the container
+const containerWorkspace = "/workspace"
+
+// Time allowed to remove the container and volume of a run after it stopped
+const containerCleanupTimeout = 30 * time.Second
+
+// Images built by this process (built once even when tasks run concurrently)
+var (
+	containerImagesMu sync.Mutex
+	containerImages   = map[string]bool{}
+)
+
+// Runs the AI tool of a task inside a Docker/Podman container
+//
+// The cloned repository is copied into a volume that is the only storage the
+// container can write to. After the AI tool finished, the working tree is
+// copied back out of the volume (without .git) so that the changes are
+// committed and pushed from the host.
+type containerBackend struct {
+	runtime      string
+	image        string
+	dockerfile   string
+	buildContext string
+	env          []string
+	runArgs      []string
+	limits       resourceLimits
+
+	// Names of the volume and containers of the run
+	name string
+	tr   *transcript
+}
+
+// Create the backend for the run recorded in tr (name identifies the run, e.g. the work directory name)
+func newContainerBackend(cfg *config.Config, tr *transcript, name string, limits resourceLimits) *containerBackend {
+	c := &containerBackend{
+		runtime:      cfg.Container.Runtime,
+		image:        cfg.Container.Image,
+		dockerfile:   cfg.Container.Dockerfile,
+		buildContext: cfg.Container.BuildContext,
+		env:          cfg.Container.Env,
+		runArgs:      cfg.Container.RunArgs,
+		limits:       limits,
+		name:         "aidd-" + name,
+		tr:           tr,
+	}
+
+	if c.runtime == "" {
+		c.runtime = defaultContainerRuntime
+	}
+
+	// Pass the environment variables of the AI tool too
+	if agent, err := lookupAgent(cfg, cfg.AI.Type); err == nil {
+		c.env = append(slices.Clone(c.env), agentEnvNames(agent)...)
+	}
+	if c.dockerfile == "" {
+		c.dockerfile = defaultContainerDockerfile
+	}
+	if c.buildContext == "" {
+		c.buildContext = defaultContainerBuildContext
+	}
+
+	return c
+}
+
+// Build the image from the Dockerfile unless an image is set or it was already built
+func (c *containerBackend) ensureImage(ctx context.Context) error {
+	if c.image != "" {
+		return nil
+	}
+	c.image = defaultContainerImage
+
+	containerImagesMu.Lock()
+	defer containerImagesMu.Unlock()
+
+	if containerImages[c.image] {
+		return nil
+	}
+
+	cmd := newCmd(ctx, "", c.runtime, "build", "-t", c.image, "-f", c.dockerfile, c.buildContext)
+	if _, err := c.tr.output(cmd); err != nil {
+		return fmt.Errorf("failed to build container image: %w", err)
+	}
+	containerImages[c.image] = true
+
+	return nil
+}
+
+// Create the volume of the run and copy the cloned repository into it
+func (c *containerBackend) prepare(ctx context.Context, repoDir string) error {
+	if err := c.ensureImage(ctx); err != nil {
+		return err
+	}
+
+	if _, err := c.tr.output(newCmd(ctx, "", c.runtime, "volume", "create", c.name)); err != nil {
+		return fmt.Errorf("failed to create volume: %w", err)
+	}
+
+	if err := c.withVolume(ctx, func(container string) error {
+		_, err := c.tr.output(newCmd(ctx, "", c.runtime, "cp", repoDir+string(filepath.Separator)+".", container+":"+containerWorkspace))
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to copy repository into volume: %w", err)
+	}
+
+	return nil
+}
+
+// Wrap the command line of the AI tool so that it runs in a container on the volume
+func (c *containerBackend) command(args []string, stdin bool) []string {
+	cmdArgs := []string{c.runtime, "run", "--rm",
+		"--name", c.name,
+		"-v", c.name + ":" + containerWorkspace,
+		"-w", containerWorkspace,
+	}
+	if stdin {
+		cmdArgs = append(cmdArgs, "-i")
+	}
+
+	// Pass the environment variables (e.g. API keys) by name so that their values don't appear in the transcript
+	for _, name := range c.env {
+		cmdArgs = append(cmdArgs, "-e", name)
+	}
+
+	// Limits enforced by the runtime (limits.disk can't be applied to the volume)
+	if c.limits.cpuTime > 0 {
+		// RLIMIT_CPU applies to each process of the container, in whole seconds
+		seconds := strconv.FormatInt(int64(math.Ceil(c.limits.cpuTime.Seconds())), 10)
+		cmdArgs = append(cmdArgs, "--ulimit", "cpu="+seconds+":"+seconds)
+	}
+	if c.limits.memory > 0 {
+		cmdArgs = append(cmdArgs, "--memory", strconv.FormatInt(c.limits.memory, 10))
+	}
+	if c.limits.maxProcesses > 0 {
+		cmdArgs = append(cmdArgs, "--pids-limit", strconv.Itoa(c.limits.maxProcesses))
+	}
+	cmdArgs = append(cmdArgs, c.runArgs...)
+	cmdArgs = append(cmdArgs, c.image)
+
+	return append(cmdArgs, args...)
+}
+
+// Replace the working tree of repoDir with the working tree in the volume
+//
+// .git of the volume is discarded, so the AI tool can't change the hooks or
+// config used by git on the host.
+func (c *containerBackend) collect(ctx context.Context, workDir, repoDir string) error {
+	outDir := filepath.Join(workDir, "container_output")
+
+	if err := c.withVolume(ctx, func(container string) error {
+		_, err := c.tr.output(newCmd(ctx, "", c.runtime, "cp", container+":"+containerWorkspace+"/.", outDir))
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to copy changes out of volume: %w", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// Remove the working tree (files deleted by the AI tool must not remain)
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to read repository directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(repoDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clean repository directory: %w", err)
+		}
+	}
+
+	// Move the working tree of the volume into place
+	entries, err = os.ReadDir(outDir)
+	if err != nil {
+		return fmt.Errorf("failed to read copied changes: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		if err := os.Rename(filepath.Join(outDir, entry.Name()), filepath.Join(repoDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to apply changes: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Remove the container and volume of the run (also when the run was cancelled)
+func (c *containerBackend) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), containerCleanupTimeout)
+	defer cancel()
+
+	// Killing the CLI doesn't stop the container, so remove it by name
+	_, _ = c.tr.output(newCmd(ctx, "", c.runtime, "rm", "-f", c.name))
+	_, _ = c.tr.output(newCmd(ctx, "", c.runtime, "volume", "rm", "-f", c.name))
+}
+
+// Call fn with a stopped helper container that has the volume mounted (used to copy files)
+func (c *containerBackend) withVolume(ctx context.Context, fn func(container string) error) error {
+	helper := c.name + "-copy"
+	_, err := c.tr.output(newCmd(ctx, "", c.runtime, "create", "--name", helper, "-v", c.name+":"+containerWorkspace, c.image))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = c.tr.output(newCmd(context.Background(), "", c.runtime, "rm", "-f", helper))
+	}()
+
+	return fn(helper)
+}
+
+// Run the AI command on the host, in a container if container.enabled is set, or in the sandbox if sandbox.enabled is set
+func runAIProcessing(ctx context.Context, cfg *config.Config, tr *transcript, cmd *exec.Cmd, run *Run, workDir, repoDir string) error {
+	if cfg.Container.Enabled && cfg.Sandbox.Enabled {
+		return errors.New("container.enabled and sandbox.enabled can't be set at the same time")
+	}
+	limits, err := newResourceLimits(cfg, workDir)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Sandbox.Enabled {
+		if err := sandboxCommand(cfg, cmd, workDir, repoDir); err != nil {
+			return err
+		}
+	}
+	if !cfg.Container.Enabled {
+		return runWithLog(cfg, tr, cmd, run, limits)
+	}
+
+	backend := newContainerBackend(cfg, tr, strings.ToLower(filepath.Base(workDir)), limits)
+	defer backend.cleanup()
+
+	if limits.disk > 0 {
+		run.appendLog("[aidd] limits.disk is not applied when container.enabled is set (the volume of the run has no size limit)")
+	}
+
+	if err := backend.prepare(ctx, repoDir); err != nil {
+		return err
+	}
+
+	args := backend.command(cmd.Args, cmd.Stdin != nil)
+	containerCmd := newCmd(ctx, "", args[0], args[1:]...)
+	containerCmd.Env = cmd.Env
+	containerCmd.Stdin = cmd.Stdin
+
+	// The processes run in the container, so only the limits the runtime enforces apply
+	if err := runWithLog(cfg, tr, containerCmd, run, resourceLimits{}); err != nil {
+		return err
+	}
+
+	return backend.collect(ctx, workDir, repoDir)
+}
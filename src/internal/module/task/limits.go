@@ -0,0 +1,154 @@
+package task
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/util/size"
+)
+
+// Interval at which the resource usage of the AI processes is checked
+const limitsCheckInterval = time.Second
+
+// Interval at which the disk usage of the work directory is checked (walking it is more expensive)
+const diskCheckInterval = 10 * time.Second
+
+// Resource limits of the AI processes of a run (0 means unlimited)
+type resourceLimits struct {
+	cpuTime      time.Duration
+	memory       int64
+	maxProcesses int
+	disk         int64
+
+	// Directory whose disk usage is limited
+	dir string
+}
+
+// Resource usage of a process tree
+type processUsage struct {
+	cpuTime   time.Duration
+	memory    int64
+	processes int
+}
+
+// Read the limits settings (dir is the work directory of the run)
+func newResourceLimits(cfg *config.Config, dir string) (resourceLimits, error) {
+	memory, err := size.Parse(cfg.Limits.Memory)
+	if err != nil {
+		return resourceLimits{}, fmt.Errorf("invalid limits.memory: %w", err)
+	}
+	disk, err := size.Parse(cfg.Limits.Disk)
+	if err != nil {
+		return resourceLimits{}, fmt.Errorf("invalid limits.disk: %w", err)
+	}
+
+	return resourceLimits{
+		cpuTime:      cfg.Limits.CPUTime,
+		memory:       memory,
+		maxProcesses: cfg.Limits.MaxProcesses,
+		disk:         disk,
+		dir:          dir,
+	}, nil
+}
+
+// Whether the usage of the process tree has to be watched
+func (l resourceLimits) watchesProcesses() bool {
+	return l.cpuTime > 0 || l.memory > 0 || l.maxProcesses > 0
+}
+
+// Watch the resource usage of the AI processes of the run
+//
+// The process tree is watched from when the command started (the returned
+// started function is called with its process) until stop is called. When a
+// limit is exceeded, the run is stopped with ErrLimitExceeded.
+func watchLimits(run *Run, limits resourceLimits) (started func(*os.Process), stop func()) {
+	if !limits.watchesProcesses() && limits.disk <= 0 {
+		return nil, func() {}
+	}
+	done := make(chan struct{})
+	pids := make(chan int, 1)
+
+	go func() {
+		ticker := time.NewTicker(limitsCheckInterval)
+		defer ticker.Stop()
+
+		pid := 0
+		var maxCPUTime time.Duration
+		var lastDiskCheck time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case pid = <-pids:
+				continue
+			case <-ticker.C:
+			}
+
+			var reason string
+			if pid != 0 && limits.watchesProcesses() {
+				usage, err := processTreeUsage(pid)
+				if err != nil {
+					// Only the disk usage can be watched on this platform
+					run.appendLog(fmt.Sprintf("[aidd] Warning: resource usage of the AI tool can't be watched: %v", err))
+					limits.cpuTime, limits.memory, limits.maxProcesses = 0, 0, 0
+					continue
+				}
+
+				// Exited processes that were not waited for drop out of the total, so keep the maximum
+				maxCPUTime = max(maxCPUTime, usage.cpuTime)
+
+				switch {
+				case limits.cpuTime > 0 && maxCPUTime > limits.cpuTime:
+					reason = fmt.Sprintf("CPU time %s exceeded limits.cpu_time (%s)", maxCPUTime.Round(time.Second), limits.cpuTime)
+				case limits.memory > 0 && usage.memory > limits.memory:
+					reason = fmt.Sprintf("memory usage %s exceeded limits.memory (%s)", size.Format(usage.memory), size.Format(limits.memory))
+				case limits.maxProcesses > 0 && usage.processes > limits.maxProcesses:
+					reason = fmt.Sprintf("%d processes exceeded limits.max_processes (%d)", usage.processes, limits.maxProcesses)
+				}
+			}
+
+			if reason == "" && limits.disk > 0 && time.Since(lastDiskCheck) >= diskCheckInterval {
+				lastDiskCheck = time.Now()
+				if usage := dirSize(limits.dir); usage > limits.disk {
+					reason = fmt.Sprintf("disk usage %s of the work directory exceeded limits.disk (%s)", size.Format(usage), size.Format(limits.disk))
+				}
+			}
+
+			if reason != "" {
+				run.appendLog("[aidd] Stopped: " + reason)
+				run.stop(fmt.Errorf("%w: %s", ErrLimitExceeded, reason))
+				return
+			}
+		}
+	}()
+
+	started = func(p *os.Process) {
+		pids <- p.Pid
+	}
+
+	return started, func() {
+		close(done)
+	}
+}
+
+// Total size of the files under dir (errors, e.g. of files removed while walking, are ignored)
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	return size
+}
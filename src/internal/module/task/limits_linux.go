@@ -0,0 +1,93 @@
+//go:build linux
+
+package task
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock ticks per second of the CPU times in /proc (USER_HZ, 100 on all supported architectures)
+const clockTicks = 100
+
+// Resource usage of the process started as root and its descendants, read from /proc
+//
+// Besides the descendants, the processes in the process group of root are
+// included, so that processes re-parented after their parent exited are
+// still counted.
+func processTreeUsage(root int) (processUsage, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return processUsage{}, fmt.Errorf("failed to read /proc: %w", err)
+	}
+
+	type procStat struct {
+		ppid, pgrp int
+		cpuTicks   int64
+		rss        int64
+	}
+	stats := map[int]procStat{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+
+		// The process may have exited in the meantime
+		data, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+
+		// The fields after the command name, which may contain spaces and parentheses (see proc(5))
+		i := strings.LastIndexByte(string(data), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[i+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+
+		ppid, _ := strconv.Atoi(fields[1])
+		pgrp, _ := strconv.Atoi(fields[2])
+		var ticks int64
+		// utime, stime, cutime and cstime (CPU time of the waited-for children is added to their parent)
+		for _, f := range fields[11:15] {
+			n, _ := strconv.ParseInt(f, 10, 64)
+			ticks += n
+		}
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+		stats[pid] = procStat{ppid: ppid, pgrp: pgrp, cpuTicks: ticks, rss: rss}
+	}
+
+	// Find the processes of the tree
+	inTree := map[int]bool{}
+	var isInTree func(pid int, depth int) bool
+	isInTree = func(pid int, depth int) bool {
+		if in, ok := inTree[pid]; ok {
+			return in
+		}
+		s, ok := stats[pid]
+		in := ok && (pid == root || s.pgrp == root || (depth < len(stats) && isInTree(s.ppid, depth+1)))
+		inTree[pid] = in
+		return in
+	}
+
+	usage := processUsage{}
+	pageSize := int64(os.Getpagesize())
+	for pid, s := range stats {
+		if !isInTree(pid, 0) {
+			continue
+		}
+		usage.processes++
+		usage.cpuTime += time.Duration(s.cpuTicks) * time.Second / clockTicks
+		usage.memory += s.rss * pageSize
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package task
+
+import "errors"
+
+// The usage of a process tree is only available from /proc on Linux
+func processTreeUsage(root int) (processUsage, error) {
+	return processUsage{}, errors.New("not supported on this platform")
+}
@@ -0,0 +1,137 @@
+package task
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+)
+
+// Status of a task that hasn't been run yet (the others are those of provider.StatusRunning etc.)
+const StatusTodo = "todo"
+
+// Valid statuses of tasks, in the order they are listed when sorted by status
+var taskStatuses = []string{StatusTodo, provider.StatusRunning, provider.StatusFailed, provider.StatusDone}
+
+// Orders of the task list
+const (
+	SortByFile     = "file"
+	SortByNumber   = "number"
+	SortByPriority = "priority"
+	SortByStatus   = "status"
+)
+
+// Orders the task list can be sorted in
+var SortOrders = []string{SortByFile, SortByNumber, SortByPriority, SortByStatus}
+
+// Ranks of the priorities written as words (numbers rank as their value, e.g. 1 or P1)
+var priorityWords = map[string]int{
+	"critical": 0,
+	"highest":  0,
+	"high":     1,
+	"medium":   2,
+	"normal":   2,
+	"low":      3,
+	"lowest":   4,
+}
+
+// Normalize a status (empty is allowed and means todo)
+func parseStatus(status string) (string, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status != "" && !slices.Contains(taskStatuses, status) {
+		return "", fmt.Errorf("invalid status %q (expected one of %s)", status, strings.Join(taskStatuses, ", "))
+	}
+	return status, nil
+}
+
+// Sort the tasks (a sorted copy is returned, and tasks that rank the same keep their order)
+func SortTasks(tasks []Task, order string) ([]Task, error) {
+	var compare func(a, b Task) int
+	switch order {
+	case SortByFile, "":
+		return slices.Clone(tasks), nil
+	case SortByNumber:
+		compare = func(a, b Task) int {
+			return compareNatural(a.Number, b.Number)
+		}
+	case SortByPriority:
+		compare = func(a, b Task) int {
+			return priorityRank(a.Priority) - priorityRank(b.Priority)
+		}
+	case SortByStatus:
+		compare = func(a, b Task) int {
+			return statusRank(a.Status) - statusRank(b.Status)
+		}
+	default:
+		return nil, fmt.Errorf("invalid sort order %q (expected one of %s)", order, strings.Join(SortOrders, ", "))
+	}
+
+	sorted := slices.Clone(tasks)
+	slices.SortStableFunc(sorted, compare)
+
+	return sorted, nil
+}
+
+// Rank of a priority (lower comes first, and tasks without a priority come last)
+func priorityRank(priority string) int {
+	priority = strings.ToLower(strings.TrimSpace(priority))
+	if priority == "" {
+		return math.MaxInt32
+	}
+
+	if rank, ok := priorityWords[priority]; ok {
+		return rank
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(priority, "p")); err == nil && n >= 0 {
+		return n
+	}
+
+	// Unknown priorities come before the tasks without one
+	return math.MaxInt32 - 1
+}
+
+// Rank of a status (tasks without a status are todo)
+func statusRank(status string) int {
+	if status == "" {
+		status = StatusTodo
+	}
+	return slices.Index(taskStatuses, status)
+}
+
+// Compare strings with the numbers in them compared by value (e.g. PROJ-9 < PROJ-10)
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNum := strings.TrimLeft(aDigits, "0")
+			bNum := strings.TrimLeft(bDigits, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+			if c := strings.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) - len(b)
+}
+
+// Digits at the start of s
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
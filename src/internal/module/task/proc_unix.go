@@ -0,0 +1,19 @@
+//go:build !windows
+
+package task
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Run the command in its own process group and kill the whole group on cancellation
+//
+// AI tools spawn child processes (shells, test runners, language servers), so
+// killing only the direct child would leave them running as orphans.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
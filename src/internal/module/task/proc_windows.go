@@ -0,0 +1,10 @@
+//go:build windows
+
+package task
+
+import (
+	"os/exec"
+)
+
+// Process groups are not available on Windows, so only the direct child is killed on cancellation
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,150 @@
+package task
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+)
+
+// Files in the target repository that override the prompt templates
+const (
+	taskPromptFile     = ".aidd/task.tmpl"
+	revisionPromptFile = ".aidd/revision.tmpl"
+)
+
+// Prompt of an initial run used when neither the repository nor prompt.task sets one
+const defaultTaskPrompt = `# {{.Task.Title}}
+
+{{.Task.Body}}
+
+---
+Task: {{.Task.Number}}
+{{- if .Attachments}}
+Attachments: {{join .Attachments ", "}}
+{{- end}}
+Repository: {{.Repository.Repository}}
+Branch: {{.Branch}} (created from {{.Repository.CloneBranch}})
+`
+
+// Prompt of a revision used when neither the repository nor prompt.revision sets one
+const defaultRevisionPrompt = `{{.Revision}}
+
+---
+Repository: {{.Repository.Repository}}
+Branch: {{.Branch}} (contains the previous changes of the task, created from {{.Repository.CloneBranch}})
+`
+
+// Data available in the prompt templates
+type promptData struct {
+	// Task of the run (for a revision, only Number is set if the task is no longer listed)
+	Task Task
+	// Paths of the attachments of the task copied into the workspace (relative to its root)
+	Attachments []string
+	// Revision details (empty for an initial run)
+	Revision string
+	// Branch the changes are committed to
+	Branch string
+	// Target repository (repository, clone_branch etc. of the forge section)
+	Repository config.RepositoryConfig
+	// Hosting service of the repository
+	Forge string
+	// Settings of config.yml without secrets
+	Config promptConfig
+}
+
+// Settings of config.yml available in the prompt templates
+//
+// Templates can come from the repository (.aidd/*.tmpl), and the prompt is
+// passed on the command line and recorded in the transcript, so only settings
+// that hold no tokens, API keys or headers are included.
+type promptConfig struct {
+	Issue struct {
+		Provider string
+		Label    string
+	}
+	Forge string
+	AI    struct {
+		Type  string
+		Model string
+	}
+}
+
+// Settings of the config available in the prompt templates
+func newPromptConfig(cfg *config.Config) promptConfig {
+	var c promptConfig
+	c.Issue.Provider = cfg.Issue.Provider
+	c.Issue.Label = cfg.Issue.Label
+	c.Forge = cfg.Forge
+	c.AI.Type = cfg.AI.Type
+	c.AI.Model = cfg.AI.Model
+
+	return c
+}
+
+// Render the prompt of a run
+//
+// The template is taken from the file in the cloned repository (file) if it
+// exists, then from config.yml (configured), then the built-in one.
+// {{file "path"}} inserts a file of the repository, e.g. coding conventions.
+func renderPrompt(repoDir, file, configured, builtin string, data promptData) (string, error) {
+	name := file
+	text, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(file)))
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist):
+		name, text = "config.yml", []byte(configured)
+		if strings.TrimSpace(configured) == "" {
+			name, text = "built-in", []byte(builtin)
+		}
+	default:
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	root, err := os.OpenRoot(repoDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+	defer root.Close()
+
+	funcs := template.FuncMap{
+		// Contents of a file of the repository (empty if it doesn't exist)
+		"file": func(p string) (string, error) {
+			data, err := root.ReadFile(filepath.FromSlash(p))
+			if errors.Is(err, fs.ErrNotExist) {
+				return "", nil
+			}
+			return string(data), err
+		},
+		"join": strings.Join,
+		"trim": strings.TrimSpace,
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// Name of the forge set in the config (GitHub if not set)
+func forgeName(cfg *config.Config) string {
+	if cfg.Forge == "" {
+		return provider.DefaultForge
+	}
+
+	return cfg.Forge
+}
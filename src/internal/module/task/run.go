@@ -0,0 +1,393 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Maximum number of AI output lines kept in memory per run
+const maxLogLines = 5000
+
+// Steps of a task (or revision) run
+const (
+	StepClone       = "Clone"
+	StepBranchCheck = "Branch check"
+	StepAIRunning   = "AI running"
+	StepCommit      = "Add/Commit"
+	StepPush        = "Push"
+	StepPRCreate    = "PR create"
+	StepPRComment   = "PR comment"
+)
+
+// Status of a run
+const (
+	RunStatusQueued        = "Queued"
+	RunStatusRunning       = "Running"
+	RunStatusSucceeded     = "Succeeded"
+	RunStatusFailed        = "Failed"
+	RunStatusCancelled     = "Cancelled"
+	RunStatusTimedOut      = "Timed out"
+	RunStatusStalled       = "Stalled"
+	RunStatusLimitExceeded = "Limit exceeded"
+)
+
+// Record of a step executed during a run
+type StepRecord struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Elapsed time of the step (up to now if the step is still in progress)
+func (s StepRecord) Elapsed() time.Duration {
+	if s.FinishedAt.IsZero() {
+		return time.Since(s.StartedAt)
+	}
+	return s.FinishedAt.Sub(s.StartedAt)
+}
+
+// Progress of a single task or revision run
+//
+// A run is updated by RunTask / ExecuteAdditionalRevision while it progresses
+// and can be read concurrently (e.g. by the TUI dashboard) through Snapshot.
+type Run struct {
+	mu            sync.Mutex
+	name          string
+	status        string
+	steps         []StepRecord
+	workspace     string
+	transcriptDir string
+	prURL         string
+	err           error
+	queuedAt      time.Time
+	startedAt     time.Time
+	finishedAt    time.Time
+
+	// Cancellation of the run
+	cancelled bool
+	cancel    context.CancelCauseFunc
+
+	// Warning about the run in progress (e.g. no output from the AI tool)
+	warning string
+
+	// Output of the AI tool and the listeners attached to it
+	lastOutputAt time.Time
+	logLines     []string
+	logListeners map[int]func(line string)
+	nextListener int
+}
+
+// Point-in-time copy of a run
+type RunSnapshot struct {
+	Name          string
+	Status        string
+	Steps         []StepRecord
+	Workspace     string
+	TranscriptDir string
+	PRURL         string
+	Warning       string
+	Err           error
+	QueuedAt      time.Time
+	StartedAt     time.Time
+	FinishedAt    time.Time
+}
+
+// Current step of the run (empty if no step has started yet)
+func (s RunSnapshot) CurrentStep() (StepRecord, bool) {
+	if len(s.Steps) == 0 {
+		return StepRecord{}, false
+	}
+	return s.Steps[len(s.Steps)-1], true
+}
+
+// Elapsed time of the whole run (up to now if the run is still in progress)
+func (s RunSnapshot) Elapsed() time.Duration {
+	switch {
+	case s.StartedAt.IsZero():
+		return 0
+	case s.FinishedAt.IsZero():
+		return time.Since(s.StartedAt)
+	default:
+		return s.FinishedAt.Sub(s.StartedAt)
+	}
+}
+
+// Create a queued run with the given display name
+func NewRun(name string) *Run {
+	return &Run{
+		name:     name,
+		status:   RunStatusQueued,
+		queuedAt: time.Now(),
+	}
+}
+
+// Get a copy of the current state of the run
+func (r *Run) Snapshot() RunSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RunSnapshot{
+		Name:          r.name,
+		Status:        r.status,
+		Steps:         append([]StepRecord(nil), r.steps...),
+		Workspace:     r.workspace,
+		TranscriptDir: r.transcriptDir,
+		PRURL:         r.prURL,
+		Warning:       r.warning,
+		Err:           r.err,
+		QueuedAt:      r.queuedAt,
+		StartedAt:     r.startedAt,
+		FinishedAt:    r.finishedAt,
+	}
+}
+
+// Get the AI output received so far and attach a listener for subsequent lines
+//
+// The listener is called from the goroutine reading the AI output, one line at
+// a time, until the returned detach function is called.
+func (r *Run) AttachLog(listener func(line string)) (lines []string, detach func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.logListeners == nil {
+		r.logListeners = map[int]func(line string){}
+	}
+	id := r.nextListener
+	r.nextListener++
+	r.logListeners[id] = listener
+
+	detach = func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.logListeners, id)
+	}
+
+	return append([]string(nil), r.logLines...), detach
+}
+
+// Record that the AI tool has just written output
+func (r *Run) touchOutput() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastOutputAt = time.Now()
+}
+
+// Time elapsed since the AI tool last wrote output
+func (r *Run) outputIdle() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return time.Since(r.lastOutputAt)
+}
+
+// Get the last n lines of AI output
+func (r *Run) lastLogLines(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := max(len(r.logLines)-n, 0)
+	return append([]string(nil), r.logLines[start:]...)
+}
+
+// Set (or clear with "") the warning about the run in progress
+func (r *Run) setWarning(warning string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.warning = warning
+}
+
+// Stop the run because of its AI tool (e.g. it stalled or exceeded a resource limit)
+func (r *Run) stop(reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		r.cancel(reason)
+	}
+}
+
+// Append a line of AI output to the log and pass it to the attached listeners
+func (r *Run) appendLog(line string) {
+	r.mu.Lock()
+	r.logLines = append(r.logLines, line)
+	if over := len(r.logLines) - maxLogLines; over > 0 {
+		r.logLines = append(r.logLines[:0], r.logLines[over:]...)
+	}
+	listeners := make([]func(line string), 0, len(r.logListeners))
+	for _, listener := range r.logListeners {
+		listeners = append(listeners, listener)
+	}
+	r.mu.Unlock()
+
+	// Call the listeners without holding the lock so they can read the run
+	for _, listener := range listeners {
+		listener(line)
+	}
+}
+
+// Create a writer that appends everything written to it to the log line by line
+//
+// Use a separate writer for each stream (stdout / stderr) so partial lines are
+// not mixed, and call Flush after the command finishes.
+func (r *Run) logWriter() *lineWriter {
+	return &lineWriter{run: r}
+}
+
+// Writer that splits its input into lines for the run log
+type lineWriter struct {
+	run     *Run
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.run.touchOutput()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.run.appendLog(string(bytes.TrimSuffix(w.partial[:i], []byte("\r"))))
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Write out the last line if it was not terminated by a newline
+func (w *lineWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.run.appendLog(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// Cancel the run
+//
+// A running run is stopped (killing the processes it started); a queued run
+// is stopped as soon as it is started.
+func (r *Run) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cancelled = true
+	if r.cancel != nil {
+		r.cancel(ErrCancelled)
+	}
+}
+
+// Mark the run as started and create the context it runs with
+//
+// The context is done when parent is done, when the run is cancelled or when
+// timeout (if greater than 0) elapses. The returned function releases it.
+func (r *Run) start(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	stopTimeout := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, stopTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrTimedOut, timeout))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status = RunStatusRunning
+	r.startedAt = time.Now()
+	r.cancel = cancel
+	if r.cancelled {
+		cancel(ErrCancelled)
+	}
+
+	return ctx, func() {
+		stopTimeout()
+		cancel(nil)
+	}
+}
+
+// Finish the current step and start the next one
+func (r *Run) step(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.finishStep(now)
+	r.steps = append(r.steps, StepRecord{Name: name, StartedAt: now})
+}
+
+// Set the path of the cloned repository the run works in
+func (r *Run) setWorkspace(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.workspace = path
+}
+
+// Set the directory the transcript of the run is written to
+func (r *Run) setTranscriptDir(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.transcriptDir = path
+}
+
+// Set the URL of the pull request created by the run
+func (r *Run) setPRURL(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prURL = url
+}
+
+// Mark the run as failed with err unless it already finished with an error
+//
+// Used for errors returned by RunTask or ExecuteAdditionalRevision that the
+// run may not have recorded.
+func (r *Run) Fail(err error) {
+	r.mu.Lock()
+	recorded := r.err != nil
+	r.mu.Unlock()
+
+	if !recorded {
+		r.finish(err)
+	}
+}
+
+// Mark the run as finished with the given result
+func (r *Run) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.finishStep(now)
+	r.finishedAt = now
+	r.warning = ""
+	r.err = err
+	switch {
+	case err == nil:
+		r.status = RunStatusSucceeded
+	case errors.Is(err, ErrStalled):
+		r.status = RunStatusStalled
+	case errors.Is(err, ErrLimitExceeded):
+		r.status = RunStatusLimitExceeded
+	case errors.Is(err, ErrTimedOut):
+		r.status = RunStatusTimedOut
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled):
+		r.status = RunStatusCancelled
+	default:
+		r.status = RunStatusFailed
+	}
+}
+
+// Finish the step in progress (the caller must hold the lock)
+func (r *Run) finishStep(now time.Time) {
+	if n := len(r.steps); n > 0 && r.steps[n-1].FinishedAt.IsZero() {
+		r.steps[n-1].FinishedAt = now
+	}
+}
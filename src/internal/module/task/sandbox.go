@@ -0,0 +1,35 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/util/sandbox"
+)
+
+// Restrict the AI command to the cloned repository and the paths of the sandbox settings
+//
+// The command gets a temporary directory of its own in the work directory
+// instead of the shared /tmp. The executable of the command itself is always
+// readable (e.g. the aidd binary for the built-in agent).
+func sandboxCommand(cfg *config.Config, cmd *exec.Cmd, workDir, repoDir string) error {
+	tmpDir := filepath.Join(workDir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	opts := sandbox.Options{
+		ReadWritePaths: append([]string{repoDir, tmpDir}, cfg.Sandbox.ReadWritePaths...),
+		ReadOnlyPaths:  append([]string{cmd.Path}, cfg.Sandbox.ReadOnlyPaths...),
+		DisableNetwork: cfg.Sandbox.DisableNetwork,
+	}
+	if err := sandbox.Wrap(cmd, opts); err != nil {
+		return fmt.Errorf("failed to set up sandbox: %w", err)
+	}
+	cmd.Env = append(cmd.Environ(), "TMPDIR="+tmpDir)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Interval at which the AI output is checked for inactivity
+const stallCheckInterval = time.Second
+
+// CPU time the AI processes must use per check to count as activity without output
+// (1% of a CPU, more than an idle process waiting for a response uses)
+const stallMinCPUTime = stallCheckInterval / 100
+
+// Number of trailing AI output lines included in the stall error
+const stallTailLines = 20
+
+// Watch the AI output of the run for inactivity
+//
+// When there has been no output for warnAfter, a warning is set on the run
+// (shown on the dashboard and in the log). When there has been no output for
+// killAfter, the run is stopped with ErrStalled. A duration of 0 disables the
+// corresponding check. The process tree of the command (the returned started
+// function is called with its process) using CPU also counts as activity, on
+// Linux. The returned stop function stops watching.
+func watchStall(run *Run, warnAfter, killAfter time.Duration) (started func(*os.Process), stop func()) {
+	if warnAfter <= 0 && killAfter <= 0 {
+		return nil, func() {}
+	}
+	done := make(chan struct{})
+	pids := make(chan int, 1)
+
+	run.touchOutput()
+
+	go func() {
+		ticker := time.NewTicker(stallCheckInterval)
+		defer ticker.Stop()
+
+		pid := 0
+		var lastCPUTime time.Duration
+		warned := false
+		for {
+			select {
+			case <-done:
+				return
+			case pid = <-pids:
+				continue
+			case <-ticker.C:
+			}
+
+			// Tools that only print when they finish are still working while they use CPU
+			if pid != 0 {
+				if usage, err := processTreeUsage(pid); err != nil {
+					pid = 0
+				} else {
+					if usage.cpuTime-lastCPUTime >= stallMinCPUTime {
+						run.touchOutput()
+					}
+					lastCPUTime = max(lastCPUTime, usage.cpuTime)
+				}
+			}
+
+			idle := run.outputIdle()
+
+			// Stop the run when the AI tool seems to hang
+			if killAfter > 0 && idle >= killAfter {
+				tail := strings.Join(run.lastLogLines(stallTailLines), "\n")
+				if tail == "" {
+					tail = "(no output)"
+				}
+				run.stop(fmt.Errorf("%w: no output or CPU activity from the AI tool for %s\n\nLast output:\n%s", ErrStalled, killAfter, tail))
+				return
+			}
+
+			// Warn once per period of inactivity
+			switch {
+			case warnAfter > 0 && idle >= warnAfter && !warned:
+				warned = true
+				run.setWarning(fmt.Sprintf("No output from the AI tool for %s", idle.Round(time.Second)))
+				run.appendLog(fmt.Sprintf("[aidd] Warning: no output from the AI tool for %s", idle.Round(time.Second)))
+			case idle < warnAfter && warned:
+				warned = false
+				run.setWarning("")
+			}
+		}
+	}()
+
+	started = func(p *os.Process) {
+		pids <- p.Pid
+	}
+
+	return started, func() {
+		close(done)
+	}
+}
@@ -0,0 +1,345 @@
+package task
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markdown table (GitHub Flavored Markdown) of task.md
+//
+// Cells are encoded so that any text round-trips byte-for-byte: newlines are
+// written as <br>, carriage returns and the whitespace at the edges of a cell
+// as numeric character references, and "\", "|", unpaired backticks and the
+// text that would otherwise be decoded ("<br>", "&#13;") are escaped with "\".
+// Pipes inside inline code spans don't split hand-written cells.
+type mdTable struct {
+	Columns []string
+	// Line number of the header row
+	HeaderLine int
+	Rows       []mdRow
+}
+
+type mdRow struct {
+	// Line number of the row in the file
+	Line  int
+	Cells []string
+}
+
+// Line breaks in cells
+var brTagRegex = regexp.MustCompile(`^(?i)<br\s*/?>`)
+
+// Numeric character references (e.g. &#13; or &#x0D;)
+var charRefRegex = regexp.MustCompile(`^&#(?:[0-9]{1,7}|[xX][0-9a-fA-F]{1,6});`)
+
+// Delimiter cells between the header and the rows (e.g. --- or :---:)
+var delimiterCellRegex = regexp.MustCompile(`^:?-+:?$`)
+
+// Index of the first column with one of the names, or -1
+//
+// Names are compared ignoring case, spaces, "_" and "-" (e.g. "Base Branch" matches base_branch).
+func (t *mdTable) column(names ...string) int {
+	for _, name := range names {
+		for i, column := range t.Columns {
+			if columnKey(column) == columnKey(name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Column name as it is compared
+func columnKey(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name))
+}
+
+// Report the first of the columns missing from the header
+func (t *mdTable) requireColumns(names ...string) error {
+	for _, name := range names {
+		if t.column(name) < 0 {
+			return fmt.Errorf("line %d: missing column %q", t.HeaderLine, name)
+		}
+	}
+	return nil
+}
+
+// Value of the cell in the column (rows with fewer cells are padded with empty cells)
+func (r mdRow) cell(column int) string {
+	if column < 0 || column >= len(r.Cells) {
+		return ""
+	}
+	return r.Cells[column]
+}
+
+// Parse the table in data
+//
+// Blank lines are skipped, and lines that aren't rows of the table are
+// reported with their line numbers.
+func parseMdTable(data string) (*mdTable, error) {
+	lines := strings.Split(data, "\n")
+	table := &mdTable{}
+
+	lineNum := 0
+	for lineNum < len(lines) && strings.TrimSpace(lines[lineNum]) == "" {
+		lineNum++
+	}
+	if lineNum == len(lines) {
+		return nil, fmt.Errorf("no table found")
+	}
+
+	// Header row
+	table.HeaderLine = lineNum + 1
+	seen := map[string]bool{}
+	for _, cell := range splitRow(lines[lineNum]) {
+		name := unescapeCell(strings.TrimSpace(cell))
+		if name == "" {
+			return nil, fmt.Errorf("line %d: empty column name", table.HeaderLine)
+		}
+		if seen[columnKey(name)] {
+			return nil, fmt.Errorf("line %d: duplicate column %q", table.HeaderLine, name)
+		}
+		seen[columnKey(name)] = true
+		table.Columns = append(table.Columns, name)
+	}
+	lineNum++
+
+	// Delimiter row
+	if lineNum == len(lines) {
+		return nil, fmt.Errorf("line %d: missing delimiter row (e.g. | --- | --- |) after the header", table.HeaderLine)
+	}
+	delimiters := splitRow(lines[lineNum])
+	for _, cell := range delimiters {
+		if !delimiterCellRegex.MatchString(strings.TrimSpace(cell)) {
+			return nil, fmt.Errorf("line %d: invalid delimiter row: %q", lineNum+1, strings.TrimSpace(lines[lineNum]))
+		}
+	}
+	if len(delimiters) != len(table.Columns) {
+		return nil, fmt.Errorf("line %d: delimiter row has %d cells, but the header has %d columns", lineNum+1, len(delimiters), len(table.Columns))
+	}
+	lineNum++
+
+	// Rows
+	for ; lineNum < len(lines); lineNum++ {
+		line := lines[lineNum]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.Contains(line, "|") {
+			return nil, fmt.Errorf("line %d: not a table row: %q", lineNum+1, strings.TrimSpace(line))
+		}
+
+		cells := splitRow(line)
+		if len(cells) > len(table.Columns) {
+			return nil, fmt.Errorf("line %d: row has %d cells, but the header has %d columns (escape | in cells as \\|)", lineNum+1, len(cells), len(table.Columns))
+		}
+		for i, cell := range cells {
+			cells[i] = unescapeCell(strings.TrimSpace(cell))
+		}
+		table.Rows = append(table.Rows, mdRow{Line: lineNum + 1, Cells: cells})
+	}
+
+	return table, nil
+}
+
+// Write a table with the columns and rows (the cells are encoded)
+func writeMdTable(w io.Writer, columns []string, rows [][]string) error {
+	bw := bufio.NewWriter(w)
+
+	writeRow := func(cells []string) {
+		bw.WriteString("|")
+		for _, cell := range cells {
+			bw.WriteString(" ")
+			bw.WriteString(cell)
+			bw.WriteString(" |")
+		}
+		bw.WriteString("\n")
+	}
+
+	header := make([]string, len(columns))
+	delimiter := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = escapeCell(column)
+		delimiter[i] = "---"
+	}
+	writeRow(header)
+	writeRow(delimiter)
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escapeCell(cell)
+		}
+		writeRow(cells)
+	}
+
+	return bw.Flush()
+}
+
+// Split a row into its (raw) cells at the pipes that aren't escaped or inside code spans
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+
+	var cells []string
+	start := 0
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case '\\':
+			i += 2
+		case '`':
+			n := backtickRun(line, i)
+			if end := closingBackticks(line, i+n, n); end >= 0 {
+				i = end + n
+			} else {
+				i += n
+			}
+		case '|':
+			cells = append(cells, line[start:i])
+			i++
+			start = i
+		default:
+			i++
+		}
+	}
+	// The pipes at the start and end of the row are optional
+	if start < len(line) {
+		cells = append(cells, line[start:])
+	}
+	if strings.HasPrefix(line, "|") && len(cells) > 0 {
+		cells = cells[1:]
+	}
+
+	return cells
+}
+
+// Length of the run of backticks at i
+func backtickRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// Index of the next run of exactly n backticks from i (the end of a code span), or -1
+func closingBackticks(s string, i, n int) int {
+	for i < len(s) {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := backtickRun(s, i)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// Encode the text of a cell
+func escapeCell(s string) string {
+	// Backticks that don't form code spans are escaped, so that a code span
+	// never pairs with a backtick in another cell when the row is split
+	unpaired := make([]bool, len(s))
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(s, i)
+		if end := closingBackticks(s, i+n, n); end >= 0 {
+			i = end + n
+			continue
+		}
+		for j := i; j < i+n; j++ {
+			unpaired[j] = true
+		}
+		i += n
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == '\n':
+			b.WriteString("<br>")
+		case r == '\r':
+			b.WriteString("&#13;")
+		case r == '\\' || r == '|':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '`' && unpaired[i]:
+			b.WriteString("\\`")
+		case r == '<' && brTagRegex.MatchString(s[i:]), r == '&' && charRefRegex.MatchString(s[i:]):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case unicode.IsSpace(r) && (i == 0 || i+size == len(s)):
+			// Whitespace at the edges of a cell would be trimmed
+			fmt.Fprintf(&b, "&#%d;", r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+
+		i += size
+	}
+
+	return b.String()
+}
+
+// Decode the text of a cell
+func unescapeCell(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("\\|`<&", s[i+1]) >= 0 {
+				b.WriteByte(s[i+1])
+				i += 2
+				continue
+			}
+		case '<':
+			if loc := brTagRegex.FindStringIndex(s[i:]); loc != nil {
+				b.WriteByte('\n')
+				i += loc[1]
+				continue
+			}
+		case '&':
+			if loc := charRefRegex.FindStringIndex(s[i:]); loc != nil {
+				if r, ok := decodeCharRef(s[i : i+loc[1]]); ok {
+					b.WriteRune(r)
+					i += loc[1]
+					continue
+				}
+			}
+		}
+
+		b.WriteByte(s[i])
+		i++
+	}
+
+	return b.String()
+}
+
+// Character of a numeric character reference (e.g. &#13;)
+func decodeCharRef(ref string) (rune, bool) {
+	digits := strings.TrimSuffix(strings.TrimPrefix(ref, "&#"), ";")
+	base := 10
+	if strings.HasPrefix(digits, "x") || strings.HasPrefix(digits, "X") {
+		digits = digits[1:]
+		base = 16
+	}
+
+	n, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || n == 0 || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+
+	return rune(n), true
+}
@@ -2,41 +2,216 @@ package task
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tomoyuki65/go-aidd/internal/config"
-	"github.com/tomoyuki65/go-aidd/internal/provider/container"
-	"github.com/tomoyuki65/go-aidd/internal/provider/github"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+	"github.com/tomoyuki65/go-aidd/internal/util/download"
 )
 
 type Task struct {
-	Number int
+	// Number or key of the task (e.g. "123" or "PROJ-123")
+	Number string
 	Title  string
 	Body   string
+	Labels []string
+	// AI tool and model overriding the ai settings for the task
+	AIType  string
+	AIModel string
+	// Numbers of the tasks that must be completed before the task
+	DependsOn []string
+	// Progress of the task (todo, running, done or failed)
+	Status string
+	// Priority of the task (e.g. 1, P1 or high)
+	Priority string
+	// Branch the task branch is created from instead of repository.clone_branch
+	BaseBranch string
+	// Directory relative paths in the body (e.g. of images) are resolved against
+	Dir string
 }
 
 type CompletedTask struct {
 	BranchName string
 }
 
+// Errors reported when a run is stopped before it completes
+var (
+	ErrCancelled     = errors.New("run was cancelled")
+	ErrTimedOut      = errors.New("run timed out")
+	ErrStalled       = errors.New("run stalled")
+	ErrLimitExceeded = errors.New("resource limit exceeded")
+)
+
+// Valid task numbers or keys (e.g. 123 or PROJ-123)
+var taskNumberRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Number of the task of a branch created by RunTask (e.g. 12 of aidd/task_12)
+func TaskNumberFromBranch(branchName string) (string, bool) {
+	prefix, taskName, found := strings.Cut(branchName, "/")
+	number, isTask := strings.CutPrefix(taskName, "task_")
+	return number, found && prefix == "aidd" && isTask && taskNumberRegex.MatchString(number)
+}
+
+// Valid base branches of tasks (e.g. main or release/1.2)
+var branchNameRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+
+// Time to wait for the output pipes of a killed command to be closed
+const commandWaitDelay = 5 * time.Second
+
+// Columns of the task.md generated from the issues
+var taskMdColumns = []string{"Number", "Title", "Body"}
+
+// Serializes appends to completed_tasks.txt across concurrently running tasks
+var completedTasksMu sync.Mutex
+
+// Serializes the status updates of task.md across concurrently running tasks
+var taskMdMu sync.Mutex
+
 // Generate "task.md" from task information
 func GenerateTaskMd(cfg *config.Config) error {
-	// Switch processing by provider
-	switch cfg.Issue.Provider {
-	case "GitHub":
-		return github.GenerateTaskMd(cfg.GitHub.Repository, cfg.Issue.Label)
-	case "container":
-		return container.ExecContainer()
-	default:
-		return errors.New("unsupported provider is set")
+	// Create the provider set in the config
+	prov, err := provider.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Fetch the tasks (leave task.md as it is if the provider doesn't manage it)
+	issues, err := prov.FetchTasks()
+	if errors.Is(err, provider.ErrTaskMdNotManaged) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Keep the columns added to task.md by hand (e.g. Status or Priority) for the tasks
+	taskMdPath := filepath.Join("src", "task.md")
+	extraColumns, extraCells := loadExtraColumns(taskMdPath)
+	columns := append(slices.Clone(taskMdColumns), extraColumns...)
+
+	// Build the rows of the tasks
+	rows := make([][]string, 0, len(issues))
+	for _, issue := range issues {
+		// Download the attachments into the images directory
+		imgDir := filepath.Join("src", "images", fmt.Sprintf("issue_%s", issue.Number))
+		// (attachments that failed to download are reported and keep their URLs)
+		attachments, err := prov.FetchAttachments(issue, imgDir)
+		if err := reportFailedDownloads(issue.Number, err); err != nil {
+			return err
+		}
+
+		// Save the data URIs and the attachments on the hosts of download.hosts
+		linked, err := provider.FetchLinkedAttachments(cfg.Download, issue.Body, imgDir, attachments)
+		if err := reportFailedDownloads(issue.Number, err); err != nil {
+			return err
+		}
+		attachments = append(attachments, linked...)
+
+		// Replace the attachment URLs in the body with the paths of the downloaded files (relative to src)
+		// (longer URLs first so that a URL that is a prefix of another doesn't break it)
+		body := issue.Body
+		sort.SliceStable(attachments, func(i, j int) bool {
+			return len(attachments[i].URL) > len(attachments[j].URL)
+		})
+		for _, attachment := range attachments {
+			relPath, err := filepath.Rel("src", attachment.Path)
+			if err != nil {
+				return fmt.Errorf("failed to get relative path of attachment: %w", err)
+			}
+			body = strings.ReplaceAll(body, attachment.URL, relPath)
+		}
+
+		row := []string{issue.Number, issue.Title, body}
+		if cells, ok := extraCells[issue.Number]; ok {
+			row = append(row, cells...)
+		} else {
+			row = append(row, make([]string, len(extraColumns))...)
+		}
+		rows = append(rows, row)
+	}
+
+	// Create the src directory
+	if err := os.MkdirAll("src", 0755); err != nil {
+		return fmt.Errorf("failed to create src directory: %w", err)
+	}
+
+	// Write to task.md (the cells are escaped so that the bodies are loaded as they are)
+	file, err := os.Create(taskMdPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
 	}
+	defer file.Close()
+
+	if err := writeMdTable(file, columns, rows); err != nil {
+		return fmt.Errorf("failed to write task.md: %w", err)
+	}
+
+	// Progress goes to stderr so that stdout only has the output of the command (e.g. JSON)
+	fmt.Fprintln(os.Stderr, "Task information successfully written to task.md.")
+
+	return nil
+}
+
+// Columns of an existing task.md other than those generated, with their cells by task number
+//
+// A missing or broken task.md has no columns to keep.
+func loadExtraColumns(taskMdPath string) ([]string, map[string][]string) {
+	data, err := os.ReadFile(taskMdPath)
+	if err != nil {
+		return nil, nil
+	}
+	table, err := parseMdTable(string(data))
+	if err != nil || table.column("Number") < 0 {
+		return nil, nil
+	}
+
+	var columns []string
+	var indexes []int
+	for i, column := range table.Columns {
+		generated := slices.ContainsFunc(taskMdColumns, func(name string) bool {
+			return columnKey(name) == columnKey(column)
+		})
+		if !generated {
+			columns = append(columns, column)
+			indexes = append(indexes, i)
+		}
+	}
+
+	cells := map[string][]string{}
+	for _, row := range table.Rows {
+		values := make([]string, len(indexes))
+		for i, index := range indexes {
+			values[i] = row.cell(index)
+		}
+		cells[row.cell(table.column("Number"))] = values
+	}
+
+	return columns, cells
+}
+
+// Show the attachments that failed to download as warnings (other errors are returned)
+func reportFailedDownloads(number string, err error) error {
+	var downloadErr *download.Error
+	if !errors.As(err, &downloadErr) {
+		return err
+	}
+
+	for _, failed := range downloadErr.Failed {
+		fmt.Fprintf(os.Stderr, "Warning: failed to download an attachment of task %s: %v\n", number, failed.Err)
+	}
+
+	return nil
 }
 
 func getFilePath(fileName string) (string, error) {
@@ -54,65 +229,86 @@ func getFilePath(fileName string) (string, error) {
 	}
 
 	if taskMdPath == "" {
-		return "", fmt.Errorf("no %s found in search paths", fileName)
+		return "", fmt.Errorf("no %s found in search paths: %w", fileName, os.ErrNotExist)
 	}
 
 	return taskMdPath, nil
 }
 
-// Create commands for Git Clone
-func createCmdForGitClone(cfg *config.Config, branchName string) (*exec.Cmd, error) {
-	switch cfg.GitHub.CloneType {
-	case "SSH":
-		repositoryURL := fmt.Sprintf("git@github.com:%s.git", cfg.GitHub.Repository)
-		cmd := exec.Command("git", "clone", "-b", branchName, "--single-branch", repositoryURL)
-		return cmd, nil
-	case "HTTPS":
-		repositoryURL := fmt.Sprintf("https://github.com/%s.git", cfg.GitHub.Repository)
-		cmd := exec.Command("git", "clone", "-b", branchName, "--single-branch", repositoryURL)
-		return cmd, nil
-	case "GitHub CLI":
-		cmd := exec.Command("gh", "repo", "clone", cfg.GitHub.Repository, "--branch", branchName, "--single-branch")
-		return cmd, nil
-	default:
-		return nil, errors.New("unsupported clone type is set")
-	}
-}
-
-// Create commands for AI processing
-func createCmdForAiProcessing(cfg *config.Config, prompt string) (*exec.Cmd, error) {
-	switch cfg.AI.Type {
-	case "Gemini CLI":
-		cmd := exec.Command("gemini", "-p", prompt, "-y")
-		if len(cfg.AI.Model) > 0 {
-			cmd.Args = append(cmd.Args, "-m", cfg.AI.Model)
-		}
-		return cmd, nil
-	case "Claude Code":
-		cmd := exec.Command("claude", "-p", prompt, "-y")
-		if len(cfg.AI.Model) > 0 {
-			cmd.Args = append(cmd.Args, "-m", cfg.AI.Model)
-		}
-		return cmd, nil
-	case "Codex":
-		cmd := exec.Command("codex", "-y", prompt)
-		if len(cfg.AI.Model) > 0 {
-			cmd = exec.Command("codex", "-y", "--model", cfg.AI.Model, prompt)
-		}
-		return cmd, nil
-	case "GitHub Copilot CLI":
-		cmd := exec.Command("copilot", "-p", prompt, "--allow-all-tools")
-		if len(cfg.AI.Model) > 0 {
-			cmd.Args = append(cmd.Args, "--model", cfg.AI.Model)
-		}
-		return cmd, nil
-	default:
-		return nil, errors.New("unsupported AI type is set")
+// Create a command that runs in the given directory instead of the process working directory
+//
+// The command (including its child processes) is killed when ctx is done.
+func newCmd(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+
+	// Don't wait forever for output of grandchildren that keep the pipes open after a kill
+	cmd.WaitDelay = commandWaitDelay
+
+	return cmd
+}
+
+// Run the AI command (recording it in the transcript) and stream its stdout/stderr into the run log line by line
+//
+// The output is watched for inactivity according to task.stall_warning and
+// task.stall_timeout, and the processes started by the command are watched
+// according to limits.
+func runWithLog(cfg *config.Config, tr *transcript, cmd *exec.Cmd, run *Run, limits resourceLimits) error {
+	stdout := run.logWriter()
+	stderr := run.logWriter()
+
+	stallStarted, stopWatching := watchStall(run, cfg.Task.StallWarning, cfg.Task.StallTimeout)
+	limitsStarted, stopLimits := watchLimits(run, limits)
+	err := tr.run(cmd, stdout, stderr, func(p *os.Process) {
+		for _, started := range []func(*os.Process){stallStarted, limitsStarted} {
+			if started != nil {
+				started(p)
+			}
+		}
+	})
+	stopLimits()
+	stopWatching()
+	stdout.Flush()
+	stderr.Flush()
+
+	return err
+}
+
+// Create commands for Git Clone (the repository is cloned into workDir)
+func createCmdForGitClone(ctx context.Context, forge provider.Forge, workDir, branchName string) (*exec.Cmd, error) {
+	args, err := forge.CloneArgs(branchName)
+	if err != nil {
+		return nil, err
 	}
+
+	return newCmd(ctx, workDir, args[0], args[1:]...), nil
+}
+
+// Workspace of a run passed to the forge (commands are recorded in the transcript)
+type workspace struct {
+	ctx context.Context
+	dir string
+	tr  *transcript
+}
+
+func (w *workspace) Context() context.Context {
+	return w.ctx
+}
+
+func (w *workspace) Dir() string {
+	return w.dir
+}
+
+func (w *workspace) Output(name string, args ...string) ([]byte, error) {
+	return w.tr.output(newCmd(w.ctx, w.dir, name, args...))
 }
 
 // Add the processed branch name to completed_tasks.txt
 func addCompletedTaskToTxt(currentDir, branchName string) error {
+	completedTasksMu.Lock()
+	defer completedTasksMu.Unlock()
+
 	path := filepath.Join(currentDir, "src", "completed_tasks.txt")
 
 	// Open the file in append mode (create it if it doesn't exist)
@@ -129,74 +325,423 @@ func addCompletedTaskToTxt(currentDir, branchName string) error {
 	return nil
 }
 
-// Load task information from task.md
-func LoadTaskMd() ([]Task, error) {
-	// Open task.md
+// Report the status of the task to task.md and to the provider if issue.update_status is enabled
+//
+// Failures are only written to the run log so that they don't stop the run.
+func reportTaskStatus(cfg *config.Config, run *Run, number, status string) {
+	prov, err := provider.New(cfg)
+	if err != nil {
+		if cfg.Issue.UpdateStatus {
+			run.appendLog(fmt.Sprintf("[aidd] Failed to update the task status to %s: %v", status, err))
+		}
+		return
+	}
+
+	// Tasks loaded from task.md keep their status in its Status column
+	if _, ok := prov.(provider.Loader); !ok {
+		if err := updateTaskMdStatus(number, status); err != nil {
+			run.appendLog(fmt.Sprintf("[aidd] Failed to update the status in task.md to %s: %v", status, err))
+		}
+	}
+
+	if !cfg.Issue.UpdateStatus {
+		return
+	}
+	if err := prov.UpdateTaskStatus(number, status); err != nil {
+		run.appendLog(fmt.Sprintf("[aidd] Failed to update the task status to %s: %v", status, err))
+	}
+}
+
+// Write the status of a task to the Status column of task.md (the column is added if it is missing)
+func updateTaskMdStatus(number, status string) error {
+	taskMdMu.Lock()
+	defer taskMdMu.Unlock()
+
 	taskMdPath, err := getFilePath("task.md")
 	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(taskMdPath)
+	if err != nil {
+		return err
+	}
+
+	table, err := parseMdTable(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", taskMdPath, err)
+	}
+	numberCol := table.column("Number")
+	if numberCol < 0 {
+		return fmt.Errorf("failed to parse %s: %w", taskMdPath, table.requireColumns("Number"))
+	}
+
+	columns := table.Columns
+	statusCol := table.column("Status")
+	if statusCol < 0 {
+		columns = append(slices.Clone(columns), "Status")
+		statusCol = len(columns) - 1
+	}
+
+	found := false
+	rows := make([][]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		cells := make([]string, len(columns))
+		copy(cells, row.Cells)
+		if row.cell(numberCol) == number {
+			cells[statusCol] = status
+			found = true
+		}
+		rows = append(rows, cells)
+	}
+
+	// Leave task.md as it is if the task was removed from it
+	if !found {
+		return nil
+	}
+
+	// Write to a temporary file first so that task.md is never loaded half-written
+	tmpPath := taskMdPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := writeMdTable(file, columns, rows); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write task.md: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write task.md: %w", err)
+	}
+
+	return os.Rename(tmpPath, taskMdPath)
+}
+
+// Add the number of a task whose run succeeded to succeeded_tasks.txt
+func addSucceededTask(currentDir, number string) error {
+	completedTasksMu.Lock()
+	defer completedTasksMu.Unlock()
+
+	path := filepath.Join(currentDir, "src", "succeeded_tasks.txt")
+
+	// Open the file in append mode (create it if it doesn't exist)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.New("failed to open succeeded_tasks.txt")
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(fmt.Sprintf("%s\n", number)); err != nil {
+		return errors.New("failed to write to succeeded_tasks.txt")
+	}
+
+	return nil
+}
+
+// Load the numbers of the tasks whose runs succeeded from succeeded_tasks.txt
+func loadSucceededTasks() (map[string]bool, error) {
+	succeeded := map[string]bool{}
+
+	path, err := getFilePath("succeeded_tasks.txt")
+	if errors.Is(err, os.ErrNotExist) {
+		return succeeded, nil
+	} else if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Open(taskMdPath)
+	completedTasksMu.Lock()
+	data, err := os.ReadFile(path)
+	completedTasksMu.Unlock()
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	// Retrieve task information
-	var tasks []Task
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if number := strings.TrimSpace(line); number != "" {
+			succeeded[number] = true
+		}
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		lineNum++
+	return succeeded, nil
+}
 
-		// Skip header and separator lines
-		if lineNum <= 2 {
-			continue
+// Load the numbers of the tasks that are done
+//
+// A task is done when a run of it succeeded (succeeded_tasks.txt), whether or
+// not its branch was pushed, or when its branch is in completed_tasks.txt.
+func loadDoneTasks() (map[string]bool, error) {
+	completedTasks, err := LoadCompletedTasks()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load completed tasks: %w", err)
+	}
+
+	done, err := loadSucceededTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load succeeded tasks: %w", err)
+	}
+
+	for _, completedTask := range completedTasks {
+		if number, ok := TaskNumberFromBranch(completedTask.BranchName); ok {
+			done[number] = true
 		}
+	}
 
-		// Skip empty lines
-		if line == "" {
-			continue
+	return done, nil
+}
+
+// Check that the tasks the task depends on are done
+func checkDependencies(task Task) error {
+	if len(task.DependsOn) == 0 {
+		return nil
+	}
+
+	done, err := loadDoneTasks()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, number := range task.DependsOn {
+		if !done[number] {
+			pending = append(pending, number)
 		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("task %s depends on tasks that are not completed yet: %s", task.Number, strings.Join(pending, ", "))
+	}
+
+	return nil
+}
 
-		// Split by |
-		cols := strings.Split(line, "|")
-		// In Markdown tables, cells at the start and end are blank, so fewer than 4 cells is an error
-		if len(cols) < 4 {
-			return nil, errors.New("invalid table row at line " + strconv.Itoa(lineNum))
+// Create a new work directory under "work"
+//
+// A suffix is added when a run with the same name was started in the same
+// second, so concurrent runs never share a work directory.
+func createWorkDir(currentDir, name string) (string, error) {
+	base := filepath.Join(currentDir, "work", name)
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return "", fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	workDir := base
+	for i := 2; ; i++ {
+		err := os.Mkdir(workDir, 0755)
+		if err == nil {
+			return workDir, nil
+		} else if !errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("failed to create work directory: %w", err)
 		}
 
-		// Trim each item
-		numberStr := strings.TrimSpace(cols[1])
-		title := strings.TrimSpace(cols[2])
-		body := strings.TrimSpace(cols[3])
+		workDir = fmt.Sprintf("%s_%d", base, i)
+	}
+}
+
+// Mark the work directory of a stopped run so it is clear that its changes were not committed
+func markCancelled(workDir string, run *Run, reason error) {
+	step := "-"
+	if current, ok := run.Snapshot().CurrentStep(); ok {
+		step = current.Name
+	}
+
+	content := fmt.Sprintf("Stopped at %s during step %q.\nThe changes in this work directory were not committed.\n\nReason: %s\n",
+		time.Now().Format(time.RFC3339), step, reason)
+	os.WriteFile(filepath.Join(workDir, "CANCELLED"), []byte(content), 0644)
+}
+
+// Load the tasks from the provider if it holds them itself, otherwise from task.md
+func LoadTasks(cfg *config.Config) ([]Task, error) {
+	prov, err := provider.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, ok := prov.(provider.Loader)
+	if !ok {
+		return LoadTaskMd()
+	}
+
+	issues, err := loader.LoadTasks()
+	if err != nil {
+		return nil, err
+	}
 
-		// Convert to number
-		number, err := strconv.Atoi(numberStr)
+	tasks := make([]Task, 0, len(issues))
+	for _, issue := range issues {
+		// The number is used in branch and directory names
+		if !taskNumberRegex.MatchString(issue.Number) {
+			return nil, fmt.Errorf("invalid task number: %q", issue.Number)
+		}
+
+		status, err := parseStatus(issue.Status)
 		if err != nil {
-			return nil, fmt.Errorf("invalid number at line %d: %v", lineNum, err)
+			return nil, fmt.Errorf("task %s: %w", issue.Number, err)
+		}
+		if issue.BaseBranch != "" && !branchNameRegex.MatchString(issue.BaseBranch) {
+			return nil, fmt.Errorf("task %s: invalid base branch: %q", issue.Number, issue.BaseBranch)
 		}
 
-		// Convert <br> in body to newline ("\n")
-		body = strings.ReplaceAll(body, "<br>", "\n")
+		dependsOn, err := parseDependencies(issue.Number, issue.DependsOn)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", issue.Number, err)
+		}
 
 		tasks = append(tasks, Task{
-			Number: number,
-			Title:  title,
-			Body:   body,
+			Number:     issue.Number,
+			Title:      issue.Title,
+			Body:       issue.Body,
+			Labels:     issue.Labels,
+			AIType:     issue.AIType,
+			AIModel:    issue.AIModel,
+			DependsOn:  dependsOn,
+			Status:     status,
+			Priority:   issue.Priority,
+			BaseBranch: issue.BaseBranch,
+			Dir:        issue.Dir,
 		})
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := validateDependencies(tasks); err != nil {
 		return nil, err
 	}
 
 	return tasks, nil
 }
 
+// Load task information from task.md
+func LoadTaskMd() ([]Task, error) {
+	// Open task.md
+	taskMdPath, err := getFilePath("task.md")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(taskMdPath)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := parseMdTable(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", taskMdPath, err)
+	}
+
+	// Locate the columns by their names in the header
+	if err := table.requireColumns("Number", "Title"); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", taskMdPath, err)
+	}
+	numberCol, titleCol, bodyCol := table.column("Number"), table.column("Title"), table.column("Body")
+
+	// Optional columns (old files with only Number, Title and Body have none of them)
+	statusCol, priorityCol, labelsCol := table.column("Status"), table.column("Priority"), table.column("Labels")
+	aiTypeCol, aiModelCol := table.column("AI", "AI Type"), table.column("Model", "AI Model")
+	baseBranchCol, dependsOnCol := table.column("Base Branch", "Branch"), table.column("Depends On", "Dependencies")
+
+	// Retrieve task information
+	tasks := make([]Task, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		number := row.cell(numberCol)
+
+		// The number is used in branch and directory names
+		if !taskNumberRegex.MatchString(number) {
+			return nil, fmt.Errorf("failed to parse %s: line %d: invalid number: %q", taskMdPath, row.Line, number)
+		}
+
+		status, err := parseStatus(row.cell(statusCol))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: line %d: %w", taskMdPath, row.Line, err)
+		}
+
+		baseBranch := row.cell(baseBranchCol)
+		if baseBranch != "" && !branchNameRegex.MatchString(baseBranch) {
+			return nil, fmt.Errorf("failed to parse %s: line %d: invalid base branch: %q", taskMdPath, row.Line, baseBranch)
+		}
+
+		dependsOn, err := parseDependencies(number, splitList(row.cell(dependsOnCol)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: line %d: %w", taskMdPath, row.Line, err)
+		}
+
+		tasks = append(tasks, Task{
+			Number:     number,
+			Title:      row.cell(titleCol),
+			Body:       row.cell(bodyCol),
+			Labels:     splitList(row.cell(labelsCol)),
+			AIType:     row.cell(aiTypeCol),
+			AIModel:    row.cell(aiModelCol),
+			DependsOn:  dependsOn,
+			Status:     status,
+			Priority:   row.cell(priorityCol),
+			BaseBranch: baseBranch,
+			Dir:        filepath.Dir(taskMdPath),
+		})
+	}
+
+	if err := validateDependencies(tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", taskMdPath, err)
+	}
+
+	return tasks, nil
+}
+
+// Normalize the numbers of the tasks a task depends on (e.g. "1, 2" or "#1, #2")
+func parseDependencies(number string, deps []string) ([]string, error) {
+	var dependsOn []string
+	for _, dep := range deps {
+		value := strings.TrimPrefix(strings.TrimSpace(dep), "#")
+		if !taskNumberRegex.MatchString(value) {
+			return nil, fmt.Errorf("invalid dependency: %q", dep)
+		}
+		if value == number {
+			return nil, fmt.Errorf("depends on itself: %q", dep)
+		}
+		dependsOn = append(dependsOn, value)
+	}
+
+	return dependsOn, nil
+}
+
+// Check that the tasks depend only on loaded tasks or on tasks that are already done
+func validateDependencies(tasks []Task) error {
+	loaded := map[string]bool{}
+	for _, task := range tasks {
+		loaded[task.Number] = true
+	}
+
+	var done map[string]bool
+	for _, task := range tasks {
+		for _, dep := range task.DependsOn {
+			if loaded[dep] {
+				continue
+			}
+
+			if done == nil {
+				var err error
+				if done, err = loadDoneTasks(); err != nil {
+					return err
+				}
+			}
+			if !done[dep] {
+				return fmt.Errorf("task %s depends on unknown task %s", task.Number, dep)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Split a comma-separated cell into its trimmed values
+func splitList(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // Load the branch names of completed tasks from completed_tasks.txt
 func LoadCompletedTasks() ([]CompletedTask, error) {
 	// Open completed_tasks.txt
@@ -241,136 +786,263 @@ func LoadCompletedTasks() ([]CompletedTask, error) {
 }
 
 // Task execution process
-func RunTask(cfg *config.Config, task Task) error {
+//
+// The task runs in its own work directory and every command is scoped to it,
+// so multiple tasks can run concurrently within the same process. Progress is
+// reported to run as each step starts.
+//
+// The run stops when ctx is done, when it is cancelled through run.Cancel, or
+// when task.timeout elapses. In that case no commit is made and the work
+// directory is marked with a CANCELLED file.
+func RunTask(ctx context.Context, cfg *config.Config, task Task, run *Run) (err error) {
+	ctx, stop := run.start(ctx, cfg.Task.Timeout)
+	defer stop()
+	defer func() {
+		run.finish(err)
+	}()
+
+	// Do nothing if the run was cancelled while it was queued
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
 	// Skip if the task’s skip_run_task in the config is true
 	if cfg.Task.SkipRunTask {
 		return nil
 	}
 
+	// Don't start before the tasks it depends on are completed
+	if err := checkDependencies(task); err != nil {
+		return err
+	}
+
+	// Use the AI tool set for the task
+	cfg = taskConfig(cfg, task)
+
+	// Report the status of the task to the provider
+	reportTaskStatus(cfg, run, task.Number, provider.StatusRunning)
+	defer func() {
+		if err != nil {
+			reportTaskStatus(cfg, run, task.Number, provider.StatusFailed)
+		} else {
+			reportTaskStatus(cfg, run, task.Number, provider.StatusDone)
+		}
+	}()
+
 	// Get the current directory
 	currentDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	// Create and move to the work directory
+	// Create the work directory
 	timestamp := time.Now().Format("20060102_150405")
-	workDir := filepath.Join(".", "work", fmt.Sprintf("task_%d_%s", task.Number, timestamp))
-	os.MkdirAll(workDir, 0755)
-	os.Chdir(workDir)
+	workDir, err := createWorkDir(currentDir, fmt.Sprintf("task_%s_%s", task.Number, timestamp))
+	if err != nil {
+		return err
+	}
+
+	// Record every command executed in the work directory
+	tr, err := newTranscript(filepath.Join(workDir, "transcript"))
+	if err != nil {
+		return err
+	}
+	run.setTranscriptDir(tr.dir)
+
+	// Report cancellation instead of the error of the killed command, and mark the work directory
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = context.Cause(ctx)
+			markCancelled(workDir, run, err)
+		}
+	}()
 
-	// Clone the target repository and move into its directory
-	cmdGitClone, err := createCmdForGitClone(cfg, cfg.GitHub.CloneBranch)
+	// Create the forge hosting the target repository
+	forge, err := provider.NewForge(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create forge: %w", err)
+	}
+	repo := forge.Repository()
+
+	// Create the task branch from the base branch set for the task (also the base of the pull request)
+	if task.BaseBranch != "" {
+		repo.CloneBranch = task.BaseBranch
+	}
+
+	// Clone the target repository into the work directory
+	run.step(StepClone)
+	cmdGitClone, err := createCmdForGitClone(ctx, forge, workDir, repo.CloneBranch)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to create cmdGitClone: %w", err)
 	}
 
-	_, err = cmdGitClone.Output()
+	_, err = tr.output(cmdGitClone)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
-	repoName := strings.Split(cfg.GitHub.Repository, "/")[1]
-	os.Chdir(repoName)
+	repoDir := filepath.Join(workDir, path.Base(repo.Repository))
+	run.setWorkspace(repoDir)
 
 	// Check if the branch exists
-	branchName := fmt.Sprintf("aidd/task_%d", task.Number)
-	cmdCheckBranch := exec.Command("git", "ls-remote", "--heads", "origin", branchName)
-	out, err := cmdCheckBranch.Output()
+	run.step(StepBranchCheck)
+	branchName := fmt.Sprintf("aidd/task_%s", task.Number)
+	cmdCheckBranch := newCmd(ctx, repoDir, "git", "ls-remote", "--heads", "origin", branchName)
+	out, err := tr.output(cmdCheckBranch)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to check branch: %w", err)
 	} else if len(out) > 0 {
-		os.Chdir(currentDir)
 		return fmt.Errorf("branch '%s' already exists", branchName)
 	}
 
 	// Create the branch
-	cmdGitCheckout := exec.Command("git", "checkout", "-b", branchName)
-	_, err = cmdGitCheckout.Output()
+	cmdGitCheckout := newCmd(ctx, repoDir, "git", "checkout", "-b", branchName)
+	_, err = tr.output(cmdGitCheckout)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to create branch: %w", err)
 	}
 
 	// Execute the task
-	cmdRunTask, err := createCmdForAiProcessing(cfg, task.Body)
+	run.step(StepAIRunning)
+
+	// Make the downloaded attachments of the task available in the workspace
+	body, attachments, err := stageAttachments(task, repoDir)
+	if err != nil {
+		return err
+	}
+	promptTask := task
+	promptTask.Body = body
+
+	prompt, err := renderPrompt(repoDir, taskPromptFile, cfg.Prompt.Task, defaultTaskPrompt, promptData{
+		Task:        promptTask,
+		Attachments: attachments,
+		Branch:      branchName,
+		Repository:  repo,
+		Forge:       forgeName(cfg),
+		Config:      newPromptConfig(cfg),
+	})
+	if err != nil {
+		return err
+	}
+
+	cmdRunTask, err := createCmdForAiProcessing(ctx, cfg, repoDir, prompt, imageAttachments(attachments))
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to create cmdRunTask: %w", err)
 	}
 
-	_, err = cmdRunTask.Output()
+	err = runAIProcessing(ctx, cfg, tr, cmdRunTask, run, workDir, repoDir)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to run task: %w", err)
 	}
 
 	// Commit process
-	cmdGitAdd := exec.Command("git", "add", "-A")
-	_, err = cmdGitAdd.Output()
+	run.step(StepCommit)
+	cmdGitAdd := newCmd(ctx, repoDir, "git", "add", "-A")
+	_, err = tr.output(cmdGitAdd)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to git add files: %w", err)
 	}
 
-	commitMsg := fmt.Sprintf("aidd: [task_%d] %s", task.Number, task.Title)
-	cmdGitCommit := exec.Command("git", "commit", "-m", commitMsg)
-	_, err = cmdGitCommit.Output()
+	commitMsg := fmt.Sprintf("aidd: [task_%s] %s", task.Number, task.Title)
+	cmdGitCommit := newCmd(ctx, repoDir, "git", "commit", "-m", commitMsg)
+	_, err = tr.output(cmdGitCommit)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to git commit: %w", err)
 	}
 
-	// Push to GitHub
-	if cfg.GitHub.PushBranchOnComplete {
-		cmdGitPush := exec.Command("git", "push", "-u", "origin", branchName)
-		_, err = cmdGitPush.Output()
+	// Push to the forge
+	if repo.PushBranchOnComplete {
+		run.step(StepPush)
+		cmdGitPush := newCmd(ctx, repoDir, "git", "push", "-u", "origin", branchName)
+		_, err = tr.output(cmdGitPush)
 		if err != nil {
-			os.Chdir(currentDir)
 			return fmt.Errorf("failed to git push: %w", err)
 		}
 
 		// Append the pushed branch name to completed_tasks.txt
 		if err := addCompletedTaskToTxt(currentDir, branchName); err != nil {
-			os.Chdir(currentDir)
 			return fmt.Errorf("failed to addCompletedTaskToTxt: %w", err)
 		}
 
 		// Create a pull request
-		if cfg.GitHub.CreatePrOnComplete {
-			bodyText := fmt.Sprintf("【Task Detail】\n%s", task.Body)
-
-			cmdCreatePullRequest := exec.Command("gh", "pr", "create",
-				"--base", cfg.GitHub.CloneBranch,
-				"--head", branchName,
-				"--title", commitMsg,
-				"--body", bodyText,
-				"--label", cfg.Issue.Label,
-			)
-
-			if cfg.GitHub.PrDraft {
-				cmdCreatePullRequest.Args = append(cmdCreatePullRequest.Args, "--draft")
-			}
-
-			_, err = cmdCreatePullRequest.Output()
+		if repo.CreatePrOnComplete {
+			run.step(StepPRCreate)
+			ws := &workspace{ctx: ctx, dir: repoDir, tr: tr}
+			prURL, err := forge.CreatePullRequest(ws, provider.PullRequest{
+				Base:  repo.CloneBranch,
+				Head:  branchName,
+				Title: commitMsg,
+				Body:  fmt.Sprintf("【Task Detail】\n%s", task.Body),
+				Label: cfg.Issue.Label,
+				Draft: repo.PrDraft,
+			})
 			if err != nil {
-				os.Chdir(currentDir)
 				return fmt.Errorf("failed to create pull request: %w", err)
 			}
+
+			run.setPRURL(prURL)
+		}
+	}
+
+	// Comment on the task with the result
+	if cfg.Issue.CommentOnComplete {
+		comment := fmt.Sprintf("aidd completed this task on branch `%s`.", branchName)
+		if prURL := run.Snapshot().PRURL; prURL != "" {
+			comment = fmt.Sprintf("aidd completed this task.\n\nPull request: %s", prURL)
+		}
+
+		prov, err := provider.New(cfg)
+		if err == nil {
+			err = prov.CommentOnTask(task.Number, comment)
+		}
+		if err != nil {
+			run.appendLog(fmt.Sprintf("[aidd] Failed to comment on the task: %v", err))
 		}
 	}
 
-	// Return to the current directory
-	os.Chdir(currentDir)
+	// Record the success so that the tasks depending on it can start (even if the branch isn't pushed)
+	if err := addSucceededTask(currentDir, task.Number); err != nil {
+		return fmt.Errorf("failed to addSucceededTask: %w", err)
+	}
 
 	return nil
 }
 
+// Config with the AI tool set for the task (if any) instead of the ai settings
+func taskConfig(cfg *config.Config, task Task) *config.Config {
+	if task.AIType == "" && task.AIModel == "" {
+		return cfg
+	}
+
+	taskCfg := *cfg
+	if task.AIType != "" {
+		taskCfg.AI.Type = task.AIType
+		taskCfg.AI.Model = ""
+	}
+	if task.AIModel != "" {
+		taskCfg.AI.Model = task.AIModel
+	}
+
+	return &taskCfg
+}
+
 // Execute additional revision process
-func ExecuteAdditionalRevision(cfg *config.Config, branchName, revisionDetails string) error {
+//
+// Like RunTask, the revision runs in its own work directory without changing
+// the process working directory and reports its progress to run. It can be
+// cancelled and times out in the same way as RunTask.
+func ExecuteAdditionalRevision(ctx context.Context, cfg *config.Config, branchName, revisionDetails string, run *Run) (err error) {
+	ctx, stop := run.start(ctx, cfg.Task.Timeout)
+	defer stop()
+	defer func() {
+		run.finish(err)
+	}()
+
+	// Do nothing if the run was cancelled while it was queued
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
 	// Skip if the task’s skip_exec_revision in the config is true
 	if cfg.Task.SkipExecRevision {
 		return nil
@@ -382,82 +1054,129 @@ func ExecuteAdditionalRevision(cfg *config.Config, branchName, revisionDetails s
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	// Create and move to the work directory
+	// Create the work directory
 	timestamp := time.Now().Format("20060102_150405")
-	taskName := strings.Split(branchName, "/")[1]
-	workDir := filepath.Join(".", "work", fmt.Sprintf("revision_%s_%s", taskName, timestamp))
-	// workDir := filepath.Join(".", "work", fmt.Sprintf("%s_%s", branchName, timestamp))
-	os.MkdirAll(workDir, 0755)
-	os.Chdir(workDir)
+	number, ok := TaskNumberFromBranch(branchName)
+	if !ok {
+		return fmt.Errorf("invalid branch %q: only task branches (aidd/task_<number>) can be revised", branchName)
+	}
+	taskName := "task_" + number
+
+	// Use the AI tool set for the task (the task may no longer be listed, e.g. when its issue is closed)
+	task := Task{Number: number}
+	if tasks, err := LoadTasks(cfg); err != nil {
+		run.appendLog(fmt.Sprintf("[aidd] Failed to load the task of the branch, so the ai settings are used: %v", err))
+	} else if i := slices.IndexFunc(tasks, func(t Task) bool { return t.Number == number }); i >= 0 {
+		task = tasks[i]
+		cfg = taskConfig(cfg, task)
+	}
+
+	workDir, err := createWorkDir(currentDir, fmt.Sprintf("revision_%s_%s", taskName, timestamp))
+	if err != nil {
+		return err
+	}
+
+	// Record every command executed in the work directory
+	tr, err := newTranscript(filepath.Join(workDir, "transcript"))
+	if err != nil {
+		return err
+	}
+	run.setTranscriptDir(tr.dir)
+
+	// Report cancellation instead of the error of the killed command, and mark the work directory
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = context.Cause(ctx)
+			markCancelled(workDir, run, err)
+		}
+	}()
 
-	// Clone the target repository and move into its directory
-	cmdGitClone, err := createCmdForGitClone(cfg, branchName)
+	// Create the forge hosting the target repository
+	forge, err := provider.NewForge(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create forge: %w", err)
+	}
+	repo := forge.Repository()
+
+	// The task branch was created from the base branch set for the task
+	if task.BaseBranch != "" {
+		repo.CloneBranch = task.BaseBranch
+	}
+
+	// Clone the target repository into the work directory
+	run.step(StepClone)
+	cmdGitClone, err := createCmdForGitClone(ctx, forge, workDir, branchName)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to create cmdGitClone: %w", err)
 	}
 
-	_, err = cmdGitClone.Output()
+	_, err = tr.output(cmdGitClone)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
-	repoName := strings.Split(cfg.GitHub.Repository, "/")[1]
-	os.Chdir(repoName)
+	repoDir := filepath.Join(workDir, path.Base(repo.Repository))
+	run.setWorkspace(repoDir)
 
 	// Execute re revise
-	cmdReRevise, err := createCmdForAiProcessing(cfg, revisionDetails)
+	run.step(StepAIRunning)
+	prompt, err := renderPrompt(repoDir, revisionPromptFile, cfg.Prompt.Revision, defaultRevisionPrompt, promptData{
+		Task:       task,
+		Revision:   revisionDetails,
+		Branch:     branchName,
+		Repository: repo,
+		Forge:      forgeName(cfg),
+		Config:     newPromptConfig(cfg),
+	})
+	if err != nil {
+		return err
+	}
+
+	cmdReRevise, err := createCmdForAiProcessing(ctx, cfg, repoDir, prompt, nil)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to create cmdReRevise: %w", err)
 	}
 
-	_, err = cmdReRevise.Output()
+	err = runAIProcessing(ctx, cfg, tr, cmdReRevise, run, workDir, repoDir)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to run re revise process: %w", err)
 	}
 
 	// Commit process
-	cmdGitAdd := exec.Command("git", "add", "-A")
-	_, err = cmdGitAdd.Output()
+	run.step(StepCommit)
+	cmdGitAdd := newCmd(ctx, repoDir, "git", "add", "-A")
+	_, err = tr.output(cmdGitAdd)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to git add files: %w", err)
 	}
 
 	commitMsg := fmt.Sprintf("aidd: [%s_%s] Revision", branchName, timestamp)
-	cmdGitCommit := exec.Command("git", "commit", "-m", commitMsg)
-	_, err = cmdGitCommit.Output()
+	cmdGitCommit := newCmd(ctx, repoDir, "git", "commit", "-m", commitMsg)
+	_, err = tr.output(cmdGitCommit)
 	if err != nil {
-		os.Chdir(currentDir)
 		return fmt.Errorf("failed to git commit: %w", err)
 	}
 
-	// Push to GitHub
-	if cfg.GitHub.PushBranchOnComplete {
-		cmdGitPush := exec.Command("git", "push", "-u", "origin", branchName)
-		_, err = cmdGitPush.Output()
+	// Push to the forge
+	if repo.PushBranchOnComplete {
+		run.step(StepPush)
+		cmdGitPush := newCmd(ctx, repoDir, "git", "push", "-u", "origin", branchName)
+		_, err = tr.output(cmdGitPush)
 		if err != nil {
-			os.Chdir(currentDir)
 			return fmt.Errorf("failed to git push: %w", err)
 		}
 
 		// Add a comment with the correction details to the PR (assuming the PR has already been created)
-		if cfg.GitHub.CreatePrOnComplete {
+		if repo.CreatePrOnComplete {
+			run.step(StepPRComment)
 			bodyText := fmt.Sprintf("【Revision details】\n%s", revisionDetails)
-			cmdAddCommentToPR := exec.Command("gh", "pr", "comment", "--body", bodyText)
-			_, err = cmdAddCommentToPR.Output()
+			ws := &workspace{ctx: ctx, dir: repoDir, tr: tr}
+			err = forge.CommentOnPullRequest(ws, branchName, bodyText)
 			if err != nil {
-				os.Chdir(currentDir)
 				return fmt.Errorf("failed to add comment to PR: %w", err)
 			}
 		}
 	}
 
-	// Return to the current directory
-	os.Chdir(currentDir)
-
 	return nil
 }
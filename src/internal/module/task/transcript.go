@@ -0,0 +1,245 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of trailing output lines of a failed command shown in its error
+const errorTailLines = 20
+
+// Transcript of the commands executed by a run
+//
+// Every command is recorded in commands.log (command line, working directory,
+// exit code and timings), and its stdout / stderr are written to separate
+// files in the transcript directory.
+type transcript struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+// Error of a command that failed during a run
+type CommandError struct {
+	Args          []string
+	ExitCode      int
+	OutputTail    string
+	TranscriptDir string
+	Err           error
+}
+
+func (e *CommandError) Error() string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "`%s` ", shortenCmdLine(quoteArgs(e.Args)))
+	if e.ExitCode >= 0 {
+		fmt.Fprintf(&msg, "exited with code %d", e.ExitCode)
+	} else {
+		fmt.Fprintf(&msg, "failed: %v", e.Err)
+	}
+	if e.OutputTail != "" {
+		fmt.Fprintf(&msg, "\n\n%s", e.OutputTail)
+	}
+	fmt.Fprintf(&msg, "\n\n(full transcript: %s)", e.TranscriptDir)
+
+	return msg.String()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Create the transcript directory
+func newTranscript(dir string) (*transcript, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+
+	return &transcript{dir: dir}, nil
+}
+
+// Run the command, record it in the transcript and return its stdout
+func (t *transcript) output(cmd *exec.Cmd) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := t.run(cmd, &stdout, nil, nil)
+
+	return stdout.Bytes(), err
+}
+
+// Run the command and record it in the transcript
+//
+// Besides the transcript files, stdout and stderr are also written to the
+// given writers (if not nil). started (if not nil) is called with the process
+// once the command started.
+func (t *transcript) run(cmd *exec.Cmd, stdout, stderr io.Writer, started func(*os.Process)) error {
+	t.mu.Lock()
+	t.seq++
+	name := fmt.Sprintf("%03d_%s", t.seq, filepath.Base(cmd.Args[0]))
+	t.mu.Unlock()
+
+	// Create the output files
+	stdoutFile, err := os.Create(filepath.Join(t.dir, name+".stdout.log"))
+	if err != nil {
+		return fmt.Errorf("failed to create transcript file: %w", err)
+	}
+	defer stdoutFile.Close()
+
+	stderrFile, err := os.Create(filepath.Join(t.dir, name+".stderr.log"))
+	if err != nil {
+		return fmt.Errorf("failed to create transcript file: %w", err)
+	}
+	defer stderrFile.Close()
+
+	stdoutTail := &tailBuffer{}
+	stderrTail := &tailBuffer{}
+	cmd.Stdout = teeWriter(stdoutFile, stdoutTail, stdout)
+	cmd.Stderr = teeWriter(stderrFile, stderrTail, stderr)
+
+	// Execute the command
+	startedAt := time.Now()
+	runErr := cmd.Start()
+	if runErr == nil {
+		if started != nil {
+			started(cmd.Process)
+		}
+		runErr = cmd.Wait()
+	}
+	finishedAt := time.Now()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	// Append the command to commands.log
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "[%s] %s\n", name, quoteArgs(cmd.Args))
+	fmt.Fprintf(&entry, "  dir:      %s\n", cmd.Dir)
+	fmt.Fprintf(&entry, "  started:  %s\n", startedAt.Format(time.RFC3339))
+	fmt.Fprintf(&entry, "  duration: %s\n", finishedAt.Sub(startedAt).Round(time.Millisecond))
+	fmt.Fprintf(&entry, "  exit:     %d\n", exitCode)
+	if runErr != nil {
+		fmt.Fprintf(&entry, "  error:    %v\n", runErr)
+	}
+	fmt.Fprintf(&entry, "  stdout:   %s.stdout.log\n", name)
+	fmt.Fprintf(&entry, "  stderr:   %s.stderr.log\n\n", name)
+	if err := t.appendLog(entry.String()); err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		// Show stderr, or stdout if the command wrote its error there
+		outputTail := stderrTail.String()
+		if outputTail == "" {
+			outputTail = stdoutTail.String()
+		}
+
+		return &CommandError{
+			Args:          cmd.Args,
+			ExitCode:      exitCode,
+			OutputTail:    outputTail,
+			TranscriptDir: t.dir,
+			Err:           runErr,
+		}
+	}
+
+	return nil
+}
+
+// Append an entry to commands.log
+func (t *transcript) appendLog(entry string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(t.dir, "commands.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open commands.log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to write to commands.log: %w", err)
+	}
+
+	return nil
+}
+
+// Combine the writers, skipping nil ones
+func teeWriter(writers ...io.Writer) io.Writer {
+	var targets []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			targets = append(targets, w)
+		}
+	}
+
+	return io.MultiWriter(targets...)
+}
+
+// Writer that keeps only the last lines written to it
+type tailBuffer struct {
+	lines   []string
+	partial []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.partial = append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(b.partial, '\n')
+		if i < 0 {
+			break
+		}
+		b.add(string(bytes.TrimSuffix(b.partial[:i], []byte("\r"))))
+		b.partial = b.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) add(line string) {
+	b.lines = append(b.lines, line)
+	if over := len(b.lines) - errorTailLines; over > 0 {
+		b.lines = b.lines[over:]
+	}
+}
+
+// Get the trailing lines (including an unterminated last line)
+func (b *tailBuffer) String() string {
+	lines := b.lines
+	if len(b.partial) > 0 {
+		lines = append(append([]string(nil), lines...), string(b.partial))
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Format a command line so it can be copied into a shell
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`|&;<>()*?[]{}!#~") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// Shorten a long command line (e.g. one containing the prompt) for error messages
+func shortenCmdLine(cmdLine string) string {
+	const maxLen = 120
+
+	if runes := []rune(cmdLine); len(runes) > maxLen {
+		return string(runes[:maxLen]) + "..."
+	}
+
+	return cmdLine
+}
@@ -2,17 +2,69 @@ package container
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
 )
 
-func ExecContainer() error {
-	cmd := exec.Command("go", "version")
+// Provider for local development
+//
+// It doesn't fetch tasks (a hand-written task.md is used) and only checks
+// that the container runtime needed to run them is available. Selecting it
+// enables container.enabled, so the AI tool of every task runs in a container.
+type Container struct {
+	// Container runtime (docker or podman)
+	runtime string
+}
+
+func init() {
+	provider.Register("container", func(cfg *config.Config) (provider.Provider, error) {
+		c := &Container{runtime: cfg.Container.Runtime}
+		if c.runtime == "" {
+			c.runtime = "docker"
+		}
+		return c, nil
+	})
+}
+
+// Check that the container runtime is available by printing its version
+func ExecContainer(runtime string) error {
+	cmd := exec.Command(runtime, "--version")
+
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to execute 'go version': %w", err)
+		return fmt.Errorf("failed to execute '%s': %w", strings.Join(cmd.Args, " "), err)
+	}
+
+	// Written to stderr so that stdout only has the output of the command (e.g. JSON)
+	fmt.Fprintln(os.Stderr, strings.TrimSpace(string(output)))
+
+	return nil
+}
+
+// Check the container runtime and leave task.md as it is
+func (c *Container) FetchTasks() ([]provider.Issue, error) {
+	if err := ExecContainer(c.runtime); err != nil {
+		return nil, err
 	}
 
-	fmt.Println(string(output))
+	return nil, provider.ErrTaskMdNotManaged
+}
+
+// Tasks in a hand-written task.md have no attachments to download
+func (c *Container) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	return nil, nil
+}
+
+// There is no issue to comment on for local development
+func (c *Container) CommentOnTask(number, body string) error {
+	return nil
+}
 
+// There is no issue to update for local development
+func (c *Container) UpdateTaskStatus(number, status string) error {
 	return nil
 }
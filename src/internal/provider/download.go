@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/util/download"
+	"github.com/tomoyuki65/go-aidd/internal/util/size"
+)
+
+// Create the downloader of task attachments from the download settings
+func NewDownloader(cfg config.DownloadConfig) (*download.Downloader, error) {
+	maxSize, err := size.Parse(cfg.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download.max_size: %w", err)
+	}
+
+	return download.New(download.Options{
+		Concurrency: cfg.Concurrency,
+		Retries:     cfg.Retries,
+		MaxSize:     maxSize,
+		Timeout:     cfg.Timeout,
+	}), nil
+}
+
+// Download the attachments of a task (refs are the URLs as they appear in the body, in the order of reqs)
+//
+// Attachments that failed to download are reported in a *download.Error
+// returned with the saved ones, so that a bad URL doesn't stop the others.
+func DownloadAttachments(d *download.Downloader, refs []string, reqs []download.Request) ([]Attachment, error) {
+	results := d.Download(context.Background(), reqs)
+
+	var attachments []Attachment
+	for i, r := range results {
+		if r.Err == nil {
+			attachments = append(attachments, Attachment{URL: refs[i], Path: r.SavedPath})
+		}
+	}
+	_, err := download.Saved(results)
+
+	return attachments, err
+}
+
+// Save the attachments of the body that the provider doesn't download itself
+//
+// Data URIs are decoded into files, and links to the hosts of download.hosts
+// are downloaded with the headers set for the host. Targets already in done
+// are skipped. Failures are reported in a *download.Error as by
+// DownloadAttachments.
+func FetchLinkedAttachments(cfg config.DownloadConfig, body, dir string, done []Attachment) ([]Attachment, error) {
+	skip := map[string]bool{}
+	for _, a := range done {
+		skip[a.URL] = true
+	}
+
+	downloader, err := NewDownloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+	var failed []download.Result
+	var refs []string
+	var reqs []download.Request
+	for _, target := range download.Links(body) {
+		if skip[target] {
+			continue
+		}
+		filePath := filepath.Join(dir, fmt.Sprintf("attachment_%d", len(attachments)+len(failed)+len(reqs)+1))
+
+		if download.IsDataURI(target) {
+			savedPath, err := downloader.SaveData(filePath, target)
+			if err != nil {
+				failed = append(failed, download.Result{Err: err})
+				continue
+			}
+			attachments = append(attachments, Attachment{URL: target, Path: savedPath})
+			continue
+		}
+
+		host, ok := matchDownloadHost(cfg.Hosts, target)
+		if !ok {
+			continue
+		}
+		header := http.Header{}
+		for name, value := range host.Headers {
+			header.Set(name, os.ExpandEnv(value))
+		}
+		refs = append(refs, target)
+		reqs = append(reqs, download.Request{URL: target, Path: filePath, Header: header})
+	}
+
+	downloaded, err := DownloadAttachments(downloader, refs, reqs)
+	attachments = append(attachments, downloaded...)
+
+	// Report the data URIs that could not be saved with the failed downloads
+	var downloadErr *download.Error
+	if errors.As(err, &downloadErr) {
+		failed = append(failed, downloadErr.Failed...)
+	} else if err != nil {
+		return attachments, err
+	}
+	if len(failed) > 0 {
+		return attachments, &download.Error{Failed: failed}
+	}
+
+	return attachments, nil
+}
+
+// Settings of the host of an http(s) URL in download.hosts
+func matchDownloadHost(hosts []config.DownloadHost, target string) (config.DownloadHost, bool) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return config.DownloadHost{}, false
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	for _, h := range hosts {
+		pattern := strings.ToLower(strings.TrimSpace(h.Host))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(hostname, "."+suffix) {
+				return h, true
+			}
+		} else if hostname == pattern {
+			return h, true
+		}
+	}
+
+	return config.DownloadHost{}, false
+}
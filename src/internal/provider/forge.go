@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+)
+
+// Forge used when forge is not set in config.yml
+const DefaultForge = "GitHub"
+
+// Workspace of a run in which a forge executes its commands
+type Workspace interface {
+	// Context of the run (done when the run is cancelled)
+	Context() context.Context
+	// Directory of the cloned repository
+	Dir() string
+	// Run a command in the workspace (recorded in the transcript of the run) and return its stdout
+	Output(name string, args ...string) ([]byte, error)
+}
+
+// Pull request (merge request) to open on a forge
+type PullRequest struct {
+	Base  string
+	Head  string
+	Title string
+	Body  string
+	Label string
+	Draft bool
+}
+
+// Hosting service of the target repository
+//
+// A forge clones the repository and opens pull requests for completed tasks.
+// Forges register a ForgeFactory under the name used for forge in config.yml.
+type Forge interface {
+	// Settings of the target repository
+	Repository() config.RepositoryConfig
+	// Command line that clones the branch of the repository into the current directory
+	CloneArgs(branch string) ([]string, error)
+	// Open a pull request and return its URL
+	CreatePullRequest(ws Workspace, pr PullRequest) (string, error)
+	// Add a comment to the open pull request of the branch
+	CommentOnPullRequest(ws Workspace, branch, body string) error
+}
+
+// Create a forge from the configuration
+type ForgeFactory func(cfg *config.Config) (Forge, error)
+
+var forgeFactories = map[string]ForgeFactory{}
+
+// Register a forge under the given name (called from the init function of the provider package)
+func RegisterForge(name string, factory ForgeFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := forgeFactories[name]; exists {
+		panic(fmt.Sprintf("forge %q is already registered", name))
+	}
+	forgeFactories[name] = factory
+}
+
+// Create the forge set in forge (GitHub if not set)
+func NewForge(cfg *config.Config) (Forge, error) {
+	name := cfg.Forge
+	if name == "" {
+		name = DefaultForge
+	}
+
+	mu.RLock()
+	factory, ok := forgeFactories[name]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported forge is set: %q (available: %s)", name, strings.Join(ForgeNames(), ", "))
+	}
+
+	return factory(cfg)
+}
+
+// Get the names of the registered forges
+func ForgeNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(forgeFactories))
+	for name := range forgeFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,412 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+	dl "github.com/tomoyuki65/go-aidd/internal/util/download"
+)
+
+// Number of items requested per page
+const pageLimit = 50
+
+// Regex to extract attachments from issue bodies (e.g. /attachments/<uuid>)
+var attachmentURLRegex = regexp.MustCompile(`(?:https?://[^\s\)\"]+)?/attachments/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+
+// Labels used to show the status of a task on the issue
+var statusLabels = map[string]string{
+	provider.StatusRunning: "aidd: running",
+	provider.StatusDone:    "aidd: done",
+	provider.StatusFailed:  "aidd: failed",
+}
+
+// Color of the labels created by aidd
+const labelColor = "#ededed"
+
+// Returned by request when the resource doesn't exist
+var errNotFound = errors.New("not found")
+
+type GiteaIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type GiteaAttachment struct {
+	Name               string `json:"name"`
+	UUID               string `json:"uuid"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type GiteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type GiteaPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+// Provider of tasks from Gitea/Forgejo issues and forge of Gitea/Forgejo repositories (uses the REST API v1)
+type Gitea struct {
+	repo     config.RepositoryConfig
+	baseURL  string
+	token    string
+	label    string
+	client   *http.Client
+	download config.DownloadConfig
+}
+
+func init() {
+	// Forgejo is a fork of Gitea with the same API
+	for _, name := range []string{"Gitea", "Forgejo"} {
+		provider.Register(name, func(cfg *config.Config) (provider.Provider, error) {
+			return New(cfg)
+		})
+		provider.RegisterForge(name, func(cfg *config.Config) (provider.Forge, error) {
+			return New(cfg)
+		})
+	}
+}
+
+// Create a provider for the issues with the label in the repository set in the config
+//
+// The personal access token is read from gitea.token, or from the GITEA_TOKEN
+// environment variable if it is not set.
+func New(cfg *config.Config) (*Gitea, error) {
+	if cfg.Gitea.BaseURL == "" {
+		return nil, errors.New("gitea.base_url is not set")
+	}
+	if cfg.Gitea.Repository == "" {
+		return nil, errors.New("gitea.repository is not set")
+	}
+
+	token := cfg.Gitea.Token
+	if token == "" {
+		token = os.Getenv("GITEA_TOKEN")
+	}
+
+	return &Gitea{
+		repo:     cfg.Gitea.RepositoryConfig,
+		baseURL:  strings.TrimSuffix(cfg.Gitea.BaseURL, "/"),
+		token:    token,
+		label:    cfg.Issue.Label,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		download: cfg.Download,
+	}, nil
+}
+
+// Fetch the open issues with the label
+func (g *Gitea) FetchTasks() ([]provider.Issue, error) {
+	var issues []provider.Issue
+
+	// Follow the pagination until a page is not full
+	for page := 1; ; page++ {
+		endpoint := fmt.Sprintf("/issues?%s", url.Values{
+			"labels": {g.label},
+			"state":  {"open"},
+			"type":   {"issues"},
+			"limit":  {fmt.Sprint(pageLimit)},
+			"page":   {fmt.Sprint(page)},
+		}.Encode())
+
+		var gtIssues []GiteaIssue
+		if err := g.request(context.Background(), http.MethodGet, endpoint, nil, &gtIssues); err != nil {
+			return nil, fmt.Errorf("failed to fetch task information: %w", err)
+		}
+
+		for _, gtIssue := range gtIssues {
+			issues = append(issues, provider.Issue{
+				Number: strconv.Itoa(gtIssue.Number),
+				Title:  gtIssue.Title,
+				Body:   gtIssue.Body,
+			})
+		}
+
+		if len(gtIssues) < pageLimit {
+			return issues, nil
+		}
+	}
+}
+
+// Download the attachments referenced in the issue body into dir
+func (g *Gitea) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	// Extract all attachments from the issue body
+	matches := attachmentURLRegex.FindAllStringSubmatch(issue.Body, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	// Look up the attachments of the issue to resolve their download URLs and names
+	var assets []GiteaAttachment
+	endpoint := fmt.Sprintf("/issues/%s/assets", url.PathEscape(issue.Number))
+	if err := g.request(context.Background(), http.MethodGet, endpoint, nil, &assets); err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments of issue #%s: %w", issue.Number, err)
+	}
+
+	assetsByUUID := make(map[string]GiteaAttachment, len(assets))
+	for _, asset := range assets {
+		assetsByUUID[asset.UUID] = asset
+	}
+
+	downloader, err := provider.NewDownloader(g.download)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the attachments in parallel
+	header := http.Header{"Authorization": {fmt.Sprintf("token %s", g.token)}}
+	refs := make([]string, 0, len(matches))
+	reqs := make([]dl.Request, 0, len(matches))
+	for i, match := range matches {
+		asset, ok := assetsByUUID[match[1]]
+		if !ok {
+			// Attachment of another issue or comment
+			asset = GiteaAttachment{BrowserDownloadURL: fmt.Sprintf("%s/attachments/%s", g.baseURL, match[1])}
+		}
+
+		refs = append(refs, match[0])
+		reqs = append(reqs, dl.Request{
+			URL:      asset.BrowserDownloadURL,
+			Path:     filepath.Join(dir, fmt.Sprintf("img_%d", i+1)),
+			FileName: asset.Name,
+			Header:   header,
+		})
+	}
+
+	return provider.DownloadAttachments(downloader, refs, reqs)
+}
+
+// Add a comment to the issue
+func (g *Gitea) CommentOnTask(number, body string) error {
+	endpoint := fmt.Sprintf("/issues/%s/comments", url.PathEscape(number))
+	if err := g.request(context.Background(), http.MethodPost, endpoint, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to comment on issue #%s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Show the status of the task as an "aidd: <status>" label on the issue
+func (g *Gitea) UpdateTaskStatus(number, status string) error {
+	if _, ok := statusLabels[status]; !ok {
+		return fmt.Errorf("unsupported task status: %s", status)
+	}
+
+	ctx := context.Background()
+	for otherStatus, label := range statusLabels {
+		// Labels are referenced by ID, so create the label if it doesn't exist yet
+		id, err := g.labelID(ctx, label, otherStatus == status)
+		if err != nil {
+			return err
+		}
+
+		if otherStatus == status {
+			endpoint := fmt.Sprintf("/issues/%s/labels", url.PathEscape(number))
+			err = g.request(ctx, http.MethodPost, endpoint, map[string][]int64{"labels": {id}}, nil)
+		} else if id != 0 {
+			// Replace the previous status label
+			endpoint := fmt.Sprintf("/issues/%s/labels/%d", url.PathEscape(number), id)
+			err = g.request(ctx, http.MethodDelete, endpoint, nil, nil)
+			if errors.Is(err, errNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update the status of issue #%s: %w", number, err)
+		}
+	}
+
+	return nil
+}
+
+// Settings of the target repository
+func (g *Gitea) Repository() config.RepositoryConfig {
+	return g.repo
+}
+
+// Command line that clones the branch of the repository from the Gitea host
+func (g *Gitea) CloneArgs(branch string) ([]string, error) {
+	var repositoryURL string
+	switch g.repo.CloneType {
+	case "SSH":
+		u, err := url.Parse(g.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gitea.base_url: %w", err)
+		}
+		repositoryURL = fmt.Sprintf("git@%s:%s.git", u.Hostname(), g.repo.Repository)
+	case "HTTPS":
+		repositoryURL = fmt.Sprintf("%s/%s.git", g.baseURL, g.repo.Repository)
+	default:
+		return nil, errors.New("unsupported clone type is set")
+	}
+
+	return []string{"git", "clone", "-b", branch, "--single-branch", repositoryURL}, nil
+}
+
+// Create a pull request and return its URL
+func (g *Gitea) CreatePullRequest(ws provider.Workspace, pr provider.PullRequest) (string, error) {
+	// Gitea marks pull requests whose title starts with "WIP:" as work in progress
+	title := pr.Title
+	if pr.Draft {
+		title = "WIP: " + title
+	}
+
+	payload := map[string]any{
+		"base":  pr.Base,
+		"head":  pr.Head,
+		"title": title,
+		"body":  pr.Body,
+	}
+
+	if pr.Label != "" {
+		id, err := g.labelID(ws.Context(), pr.Label, false)
+		if err != nil {
+			return "", err
+		}
+		if id != 0 {
+			payload["labels"] = []int64{id}
+		}
+	}
+
+	var created GiteaPullRequest
+	if err := g.request(ws.Context(), http.MethodPost, "/pulls", payload, &created); err != nil {
+		return "", err
+	}
+
+	return created.HTMLURL, nil
+}
+
+// Add a comment to the open pull request of the branch
+func (g *Gitea) CommentOnPullRequest(ws provider.Workspace, branch, body string) error {
+	number, err := g.pullRequestNumber(ws.Context(), branch)
+	if err != nil {
+		return err
+	}
+
+	// Comments of pull requests are issue comments in Gitea
+	endpoint := fmt.Sprintf("/issues/%d/comments", number)
+	return g.request(ws.Context(), http.MethodPost, endpoint, map[string]string{"body": body}, nil)
+}
+
+// Find the number of the open pull request of the branch
+func (g *Gitea) pullRequestNumber(ctx context.Context, branch string) (int, error) {
+	for page := 1; ; page++ {
+		var pulls []GiteaPullRequest
+		endpoint := fmt.Sprintf("/pulls?state=open&limit=%d&page=%d", pageLimit, page)
+		if err := g.request(ctx, http.MethodGet, endpoint, nil, &pulls); err != nil {
+			return 0, err
+		}
+
+		for _, pull := range pulls {
+			if pull.Head.Ref == branch {
+				return pull.Number, nil
+			}
+		}
+
+		if len(pulls) < pageLimit {
+			return 0, fmt.Errorf("no open pull request found for branch '%s'", branch)
+		}
+	}
+}
+
+// Find the ID of the label in the repository (0 if it doesn't exist and create is false)
+func (g *Gitea) labelID(ctx context.Context, name string, create bool) (int64, error) {
+	for page := 1; ; page++ {
+		var labels []GiteaLabel
+		endpoint := fmt.Sprintf("/labels?limit=%d&page=%d", pageLimit, page)
+		if err := g.request(ctx, http.MethodGet, endpoint, nil, &labels); err != nil {
+			return 0, fmt.Errorf("failed to fetch labels: %w", err)
+		}
+
+		for _, label := range labels {
+			if label.Name == name {
+				return label.ID, nil
+			}
+		}
+
+		if len(labels) < pageLimit {
+			break
+		}
+	}
+
+	if !create {
+		return 0, nil
+	}
+
+	var created GiteaLabel
+	payload := map[string]string{"name": name, "color": labelColor}
+	if err := g.request(ctx, http.MethodPost, "/labels", payload, &created); err != nil {
+		return 0, fmt.Errorf("failed to create label '%s': %w", name, err)
+	}
+
+	return created.ID, nil
+}
+
+// Send a request to an endpoint of the repository and decode the JSON response into out
+//
+// in is sent as the JSON request body when it is not nil.
+func (g *Gitea) request(ctx context.Context, method, endpoint string, in, out any) error {
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s%s", g.baseURL, g.repo.Repository, endpoint)
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", g.token))
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, req.URL.Path, errNotFound)
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status code %d %s: %s",
+			method, req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	return nil
+}
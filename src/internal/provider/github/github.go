@@ -2,105 +2,256 @@ package github
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"net/url"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
 	dl "github.com/tomoyuki65/go-aidd/internal/util/download"
 )
 
+// Regex of the URLs of files uploaded to issues on github.com
+var attachmentURLRegex = regexp.MustCompile(`^https://github\.com/[^\s\)\"]+/(?:user-attachments|assets|user-images)/[^\s\)\"]+`)
+
+// Other hosts of GitHub serving attachments, and whether the token is sent to them
+//
+// The URLs of private-user-images are signed, so they don't need the token.
+var attachmentHosts = map[string]bool{
+	"raw.githubusercontent.com":                 true,
+	"private-user-images.githubusercontent.com": false,
+	"user-images.githubusercontent.com":         false,
+	"objects.githubusercontent.com":             false,
+}
+
+// Labels used to show the status of a task on the issue
+var statusLabels = map[string]string{
+	provider.StatusRunning: "aidd: running",
+	provider.StatusDone:    "aidd: done",
+	provider.StatusFailed:  "aidd: failed",
+}
+
 type GitHubIssue struct {
 	Number int    `json:"number"`
 	Title  string `json:"title"`
 	Body   string `json:"body"`
 }
 
-// Generate task.md from GitHub issues for the given repository and label
-func GenerateTaskMd(repository, label string) error {
-	// Set up regex to extract image URLs
-	re := regexp.MustCompile(`https://github\.com/[^\s\)\"]+/(?:user-attachments|assets|user-images)/[^\s\)\"]+`)
+// Provider of tasks from GitHub issues and forge of GitHub repositories (uses the GitHub CLI)
+type GitHub struct {
+	repo       config.RepositoryConfig
+	repository string
+	label      string
+	token      string
+	download   config.DownloadConfig
+}
 
-	// Retrieve authentication token from GitHub CLI
-	cmdGhAuthToken := exec.Command("gh", "auth", "token")
-	outputGhAuthToken, err := cmdGhAuthToken.Output()
-	if err != nil {
-		return fmt.Errorf("failed to fetch GitHub auth token: %w", err)
+func init() {
+	provider.Register("GitHub", func(cfg *config.Config) (provider.Provider, error) {
+		return New(cfg), nil
+	})
+	provider.RegisterForge("GitHub", func(cfg *config.Config) (provider.Forge, error) {
+		return New(cfg), nil
+	})
+}
+
+// Create a provider for the issues with the label in the repository set in the config
+func New(cfg *config.Config) *GitHub {
+	return &GitHub{
+		repo:       cfg.GitHub.RepositoryConfig,
+		repository: cfg.GitHub.Repository,
+		label:      cfg.Issue.Label,
+		download:   cfg.Download,
 	}
-	token := strings.TrimSpace(string(outputGhAuthToken))
+}
 
+// Fetch the issues with the label
+func (g *GitHub) FetchTasks() ([]provider.Issue, error) {
 	// Fetch the target issue in JSON format
 	cmdGhIssueList := exec.Command("gh", "issue", "list",
-		"-R", repository,
-		"--label", label,
+		"-R", g.repository,
+		"--label", g.label,
 		"--json", "number,title,body",
 	)
 	outputGhIssueList, err := cmdGhIssueList.Output()
 	if err != nil {
-		return fmt.Errorf("failed to fetch task information: %w", err)
+		return nil, fmt.Errorf("failed to fetch task information: %w", err)
 	}
 
 	// Parse GitHub issue list JSON into Issue structs
-	var issues []GitHubIssue
-	if err := json.Unmarshal(outputGhIssueList, &issues); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+	var ghIssues []GitHubIssue
+	if err := json.Unmarshal(outputGhIssueList, &ghIssues); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	// Create the src directory
-	if err := os.MkdirAll("src", 0755); err != nil {
-		return fmt.Errorf("failed to create src directory: %w", err)
+	issues := make([]provider.Issue, 0, len(ghIssues))
+	for _, ghIssue := range ghIssues {
+		issues = append(issues, provider.Issue{
+			Number: strconv.Itoa(ghIssue.Number),
+			Title:  ghIssue.Title,
+			Body:   ghIssue.Body,
+		})
 	}
 
-	// Write to task.md
-	file, err := os.Create(filepath.Join("src", "task.md"))
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
-
-	// Write header
-	fmt.Fprintln(file, "| Number | Title | Body |")
-	fmt.Fprintln(file, "| --- | --- | --- |")
-
-	// Write tasks
-	for _, issue := range issues {
-		// Extract all URLs from the issue body
-		urls := re.FindAllString(issue.Body, -1)
-
-		// Replace newline characters (\n or \r\n) with <br>
-		safeBody := strings.ReplaceAll(issue.Body, "\n", "<br>")
-		safeBody = strings.ReplaceAll(safeBody, "\r", "")
-
-		// Escape pipe characters (|)
-		safeBody = strings.ReplaceAll(safeBody, "|", "\\|")
-		safeTitle := strings.ReplaceAll(issue.Title, "|", "\\|")
-
-		// Process each extracted URL
-		for i, url := range urls {
-			// Prepare local file path for saving issue images
-			imgDir := filepath.Join("src", "images", fmt.Sprintf("issue_%d", issue.Number))
-			os.MkdirAll(imgDir, 0755)
-
-			fileName := fmt.Sprintf("img_%d.png", i+1)
-			filePath := filepath.Join(imgDir, fileName)
-
-			// Download the image and save it to a local file
-			if err := dl.SaveImages("GitHub", url, token, filePath); err == nil {
-				// Replace the image URL in the issue body with the local image path
-				relPath := filepath.Join("images", fmt.Sprintf("issue_%d", issue.Number), fileName)
-				safeBody = strings.ReplaceAll(safeBody, url, relPath)
-			} else {
-				return fmt.Errorf("failed to download image: %w", err)
+	return issues, nil
+}
+
+// Download the images in the issue body into dir
+func (g *GitHub) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	// Extract the URLs of the files on GitHub from the images and links in the issue body
+	var urls []string
+	withToken := map[string]bool{}
+	for _, target := range dl.Links(issue.Body) {
+		if attachmentURLRegex.MatchString(target) {
+			urls = append(urls, target)
+			withToken[target] = true
+		} else if u, err := url.Parse(target); err == nil && u.Scheme == "https" {
+			if token, ok := attachmentHosts[u.Host]; ok {
+				urls = append(urls, target)
+				withToken[target] = token
 			}
 		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	token, err := g.authToken()
+	if err != nil {
+		return nil, err
+	}
+
+	downloader, err := provider.NewDownloader(g.download)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the files in parallel (the extension is taken from the content type)
+	header := http.Header{"Authorization": {fmt.Sprintf("token %s", token)}}
+	reqs := make([]dl.Request, 0, len(urls))
+	for i, target := range urls {
+		req := dl.Request{URL: target, Path: filepath.Join(dir, fmt.Sprintf("img_%d", i+1))}
+		if withToken[target] {
+			req.Header = header
+		}
+		reqs = append(reqs, req)
+	}
+
+	return provider.DownloadAttachments(downloader, urls, reqs)
+}
+
+// Add a comment to the issue
+func (g *GitHub) CommentOnTask(number, body string) error {
+	cmd := exec.Command("gh", "issue", "comment", number,
+		"-R", g.repository,
+		"--body", body,
+	)
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("failed to comment on issue #%s: %w", number, err)
+	}
+
+	return nil
+}
 
-		// Write task
-		fmt.Fprintf(file, "| %d | %s | %s |\n", issue.Number, safeTitle, safeBody)
+// Show the status of the task as an "aidd: <status>" label on the issue
+func (g *GitHub) UpdateTaskStatus(number, status string) error {
+	label, ok := statusLabels[status]
+	if !ok {
+		return fmt.Errorf("unsupported task status: %s", status)
 	}
 
-	fmt.Println("Task information successfully written to task.md.")
+	// Create the label if it doesn't exist yet
+	cmdLabel := exec.Command("gh", "label", "create", label, "-R", g.repository, "--force")
+	if _, err := cmdLabel.Output(); err != nil {
+		return fmt.Errorf("failed to create label '%s': %w", label, err)
+	}
+
+	// Replace the previous status label
+	cmdEdit := exec.Command("gh", "issue", "edit", number, "-R", g.repository, "--add-label", label)
+	for otherStatus, otherLabel := range statusLabels {
+		if otherStatus != status {
+			cmdEdit.Args = append(cmdEdit.Args, "--remove-label", otherLabel)
+		}
+	}
+	if _, err := cmdEdit.Output(); err != nil {
+		return fmt.Errorf("failed to update the status of issue #%s: %w", number, err)
+	}
 
 	return nil
 }
+
+// Settings of the target repository
+func (g *GitHub) Repository() config.RepositoryConfig {
+	return g.repo
+}
+
+// Command line that clones the branch of the repository
+func (g *GitHub) CloneArgs(branch string) ([]string, error) {
+	switch g.repo.CloneType {
+	case "SSH":
+		repositoryURL := fmt.Sprintf("git@github.com:%s.git", g.repository)
+		return []string{"git", "clone", "-b", branch, "--single-branch", repositoryURL}, nil
+	case "HTTPS":
+		repositoryURL := fmt.Sprintf("https://github.com/%s.git", g.repository)
+		return []string{"git", "clone", "-b", branch, "--single-branch", repositoryURL}, nil
+	case "GitHub CLI":
+		return []string{"gh", "repo", "clone", g.repository, "--branch", branch, "--single-branch"}, nil
+	default:
+		return nil, errors.New("unsupported clone type is set")
+	}
+}
+
+// Create a pull request with the GitHub CLI
+func (g *GitHub) CreatePullRequest(ws provider.Workspace, pr provider.PullRequest) (string, error) {
+	args := []string{"pr", "create",
+		"--base", pr.Base,
+		"--head", pr.Head,
+		"--title", pr.Title,
+		"--body", pr.Body,
+		"--label", pr.Label,
+	}
+
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+
+	output, err := ws.Output("gh", args...)
+	if err != nil {
+		return "", err
+	}
+
+	// gh prints the URL of the created pull request
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Add a comment to the pull request of the branch with the GitHub CLI
+func (g *GitHub) CommentOnPullRequest(ws provider.Workspace, branch, body string) error {
+	_, err := ws.Output("gh", "pr", "comment", branch, "--body", body)
+	return err
+}
+
+// Retrieve authentication token from GitHub CLI (once per provider)
+func (g *GitHub) authToken() (string, error) {
+	if g.token != "" {
+		return g.token, nil
+	}
+
+	cmdGhAuthToken := exec.Command("gh", "auth", "token")
+	outputGhAuthToken, err := cmdGhAuthToken.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch GitHub auth token: %w", err)
+	}
+
+	g.token = strings.TrimSpace(string(outputGhAuthToken))
+	if g.token == "" {
+		return "", errors.New("GitHub auth token is empty")
+	}
+
+	return g.token, nil
+}
@@ -0,0 +1,345 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+	dl "github.com/tomoyuki65/go-aidd/internal/util/download"
+)
+
+// GitLab instance used when gitlab.base_url is not set
+const defaultBaseURL = "https://gitlab.com"
+
+// Regex to extract uploaded files from issue descriptions (e.g. /uploads/<secret>/image.png),
+// with the URL of the project they belong to if they are absolute
+var uploadURLRegex = regexp.MustCompile(`(https?://[^\s\)\"]+)?/uploads/([0-9a-f]{32})/([^\s\)\"]+)`)
+
+// Labels used to show the status of a task on the issue
+var statusLabels = map[string]string{
+	provider.StatusRunning: "aidd: running",
+	provider.StatusDone:    "aidd: done",
+	provider.StatusFailed:  "aidd: failed",
+}
+
+type GitLabIssue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type GitLabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+// Provider of tasks from GitLab issues and forge of GitLab repositories (uses the REST API v4)
+type GitLab struct {
+	repo     config.RepositoryConfig
+	baseURL  string
+	token    string
+	label    string
+	client   *http.Client
+	download config.DownloadConfig
+}
+
+func init() {
+	provider.Register("GitLab", func(cfg *config.Config) (provider.Provider, error) {
+		return New(cfg)
+	})
+	provider.RegisterForge("GitLab", func(cfg *config.Config) (provider.Forge, error) {
+		return New(cfg)
+	})
+}
+
+// Create a provider for the issues with the label in the project set in the config
+//
+// The access token is read from gitlab.token, or from the GITLAB_TOKEN
+// environment variable if it is not set.
+func New(cfg *config.Config) (*GitLab, error) {
+	if cfg.GitLab.Repository == "" {
+		return nil, errors.New("gitlab.repository is not set")
+	}
+
+	baseURL := strings.TrimSuffix(cfg.GitLab.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	token := cfg.GitLab.Token
+	if token == "" {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+
+	return &GitLab{
+		repo:     cfg.GitLab.RepositoryConfig,
+		baseURL:  baseURL,
+		token:    token,
+		label:    cfg.Issue.Label,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		download: cfg.Download,
+	}, nil
+}
+
+// Fetch the open issues with the label
+func (g *GitLab) FetchTasks() ([]provider.Issue, error) {
+	var issues []provider.Issue
+
+	// Follow the pagination until the last page
+	page := "1"
+	for page != "" {
+		params := url.Values{
+			"labels":   {g.label},
+			"state":    {"opened"},
+			"per_page": {"100"},
+			"page":     {page},
+		}
+
+		var glIssues []GitLabIssue
+		header, err := g.request(context.Background(), http.MethodGet, "/issues", params, &glIssues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch task information: %w", err)
+		}
+
+		for _, glIssue := range glIssues {
+			issues = append(issues, provider.Issue{
+				Number: strconv.Itoa(glIssue.IID),
+				Title:  glIssue.Title,
+				Body:   glIssue.Description,
+			})
+		}
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return issues, nil
+}
+
+// Download the files uploaded to the issue description into dir
+func (g *GitLab) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	// Extract all uploads of the project from the issue description
+	matches := g.projectUploads(issue.Body)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	downloader, err := provider.NewDownloader(g.download)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the files through the uploads API (works for private projects)
+	header := http.Header{"Private-Token": {g.token}}
+	refs := make([]string, 0, len(matches))
+	reqs := make([]dl.Request, 0, len(matches))
+	for i, match := range matches {
+		secret, fileName := match[2], match[3]
+		refs = append(refs, match[0])
+		reqs = append(reqs, dl.Request{
+			URL:      g.projectURL(fmt.Sprintf("/uploads/%s/%s", secret, fileName)),
+			Path:     filepath.Join(dir, fmt.Sprintf("img_%d", i+1)),
+			FileName: fileName,
+			Header:   header,
+		})
+	}
+
+	return provider.DownloadAttachments(downloader, refs, reqs)
+}
+
+// Uploads of the project in the body (the submatches of uploadURLRegex)
+//
+// Only relative paths (/uploads/<secret>/<name>) and absolute URLs under the
+// project URL are returned, since the uploads of other projects or hosts
+// can't be fetched from the uploads endpoint of this project.
+func (g *GitLab) projectUploads(body string) [][]string {
+	projectURL := g.baseURL + "/" + g.repo.Repository
+
+	var uploads [][]string
+	for _, loc := range uploadURLRegex.FindAllStringSubmatchIndex(body, -1) {
+		start, prefixStart := loc[0], loc[2]
+		if prefixStart >= 0 {
+			if !strings.EqualFold(body[prefixStart:loc[3]], projectURL) {
+				continue
+			}
+		} else if start > 0 && !strings.ContainsRune(" \t\r\n(\"'<[", rune(body[start-1])) {
+			// A path of another site or project (e.g. other/project/uploads/...)
+			continue
+		}
+
+		match := make([]string, 4)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = body[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		uploads = append(uploads, match)
+	}
+
+	return uploads
+}
+
+// Add a comment (note) to the issue
+func (g *GitLab) CommentOnTask(number, body string) error {
+	endpoint := fmt.Sprintf("/issues/%s/notes", url.PathEscape(number))
+	if _, err := g.request(context.Background(), http.MethodPost, endpoint, url.Values{"body": {body}}, nil); err != nil {
+		return fmt.Errorf("failed to comment on issue #%s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Show the status of the task as an "aidd: <status>" label on the issue
+func (g *GitLab) UpdateTaskStatus(number, status string) error {
+	label, ok := statusLabels[status]
+	if !ok {
+		return fmt.Errorf("unsupported task status: %s", status)
+	}
+
+	// Replace the previous status label (GitLab creates missing labels)
+	var otherLabels []string
+	for otherStatus, otherLabel := range statusLabels {
+		if otherStatus != status {
+			otherLabels = append(otherLabels, otherLabel)
+		}
+	}
+
+	params := url.Values{
+		"add_labels":    {label},
+		"remove_labels": {strings.Join(otherLabels, ",")},
+	}
+	endpoint := fmt.Sprintf("/issues/%s", url.PathEscape(number))
+	if _, err := g.request(context.Background(), http.MethodPut, endpoint, params, nil); err != nil {
+		return fmt.Errorf("failed to update the status of issue #%s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Settings of the target repository
+func (g *GitLab) Repository() config.RepositoryConfig {
+	return g.repo
+}
+
+// Command line that clones the branch of the project from the GitLab host
+func (g *GitLab) CloneArgs(branch string) ([]string, error) {
+	var repositoryURL string
+	switch g.repo.CloneType {
+	case "SSH":
+		u, err := url.Parse(g.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gitlab.base_url: %w", err)
+		}
+		repositoryURL = fmt.Sprintf("git@%s:%s.git", u.Hostname(), g.repo.Repository)
+	case "HTTPS":
+		repositoryURL = fmt.Sprintf("%s/%s.git", g.baseURL, g.repo.Repository)
+	default:
+		return nil, errors.New("unsupported clone type is set")
+	}
+
+	return []string{"git", "clone", "-b", branch, "--single-branch", repositoryURL}, nil
+}
+
+// Create a merge request and return its URL
+func (g *GitLab) CreatePullRequest(ws provider.Workspace, pr provider.PullRequest) (string, error) {
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+
+	params := url.Values{
+		"source_branch": {pr.Head},
+		"target_branch": {pr.Base},
+		"title":         {title},
+		"description":   {pr.Body},
+		"labels":        {pr.Label},
+	}
+
+	var mr GitLabMergeRequest
+	if _, err := g.request(ws.Context(), http.MethodPost, "/merge_requests", params, &mr); err != nil {
+		return "", err
+	}
+
+	return mr.WebURL, nil
+}
+
+// Add a comment (note) to the open merge request of the branch
+func (g *GitLab) CommentOnPullRequest(ws provider.Workspace, branch, body string) error {
+	params := url.Values{
+		"source_branch": {branch},
+		"state":         {"opened"},
+	}
+
+	var mrs []GitLabMergeRequest
+	if _, err := g.request(ws.Context(), http.MethodGet, "/merge_requests", params, &mrs); err != nil {
+		return err
+	}
+	if len(mrs) == 0 {
+		return fmt.Errorf("no open merge request found for branch '%s'", branch)
+	}
+
+	endpoint := fmt.Sprintf("/merge_requests/%d/notes", mrs[0].IID)
+	_, err := g.request(ws.Context(), http.MethodPost, endpoint, url.Values{"body": {body}}, nil)
+	return err
+}
+
+// URL of an endpoint of the project in the REST API
+func (g *GitLab) projectURL(endpoint string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s%s", g.baseURL, url.PathEscape(g.repo.Repository), endpoint)
+}
+
+// Send a request to an endpoint of the project and decode the JSON response into out
+//
+// Parameters are sent as the query string for GET and as a form otherwise.
+func (g *GitLab) request(ctx context.Context, method, endpoint string, params url.Values, out any) (http.Header, error) {
+	reqURL := g.projectURL(endpoint)
+
+	var body io.Reader
+	if method == http.MethodGet {
+		reqURL += "?" + params.Encode()
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if g.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: unexpected status code %d %s: %s",
+			method, req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	return resp.Header, nil
+}
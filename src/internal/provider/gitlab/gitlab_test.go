@@ -0,0 +1,233 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+)
+
+const (
+	testRepository = "group/project"
+	testToken      = "glpat-test"
+	testLabel      = "AI DD"
+)
+
+// Path of the project in the REST API of the stand-in server
+var testProjectPath = "/api/v4/projects/" + strings.ReplaceAll(testRepository, "/", "%2F")
+
+// Workspace of a run for the forge methods
+type testWorkspace struct{}
+
+func (testWorkspace) Context() context.Context { return context.Background() }
+func (testWorkspace) Dir() string              { return "" }
+func (testWorkspace) Output(name string, args ...string) ([]byte, error) {
+	return nil, fmt.Errorf("unexpected command: %s", name)
+}
+
+// Create a provider that talks to the stand-in GitLab server
+func newTestGitLab(t *testing.T, handler http.Handler) *GitLab {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Issue.Label = testLabel
+	cfg.GitLab.Repository = testRepository
+	cfg.GitLab.BaseURL = server.URL + "/"
+	cfg.GitLab.Token = testToken
+
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return g
+}
+
+// Check the method, path and token of a request to the project
+func checkRequest(t *testing.T, r *http.Request, method, endpoint string) {
+	t.Helper()
+
+	if r.Method != method {
+		t.Errorf("method = %s, want %s", r.Method, method)
+	}
+	if r.URL.EscapedPath() != testProjectPath+endpoint {
+		t.Errorf("path = %s, want %s", r.URL.EscapedPath(), testProjectPath+endpoint)
+	}
+	if got := r.Header.Get("PRIVATE-TOKEN"); got != testToken {
+		t.Errorf("PRIVATE-TOKEN = %q, want %q", got, testToken)
+	}
+}
+
+func TestFetchTasks(t *testing.T) {
+	pages := map[string][]GitLabIssue{
+		"1": {{IID: 1, Title: "First", Description: "Body 1"}, {IID: 2, Title: "Second", Description: "Body 2"}},
+		"2": {{IID: 5, Title: "Third", Description: "Body 5"}},
+	}
+
+	g := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkRequest(t, r, http.MethodGet, "/issues")
+
+		query := r.URL.Query()
+		if got := query.Get("labels"); got != testLabel {
+			t.Errorf("labels = %q, want %q", got, testLabel)
+		}
+		if got := query.Get("state"); got != "opened" {
+			t.Errorf("state = %q, want opened", got)
+		}
+
+		page := query.Get("page")
+		if page == "1" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		json.NewEncoder(w).Encode(pages[page])
+	}))
+
+	issues, err := g.FetchTasks()
+	if err != nil {
+		t.Fatalf("FetchTasks: %v", err)
+	}
+
+	want := []provider.Issue{
+		{Number: "1", Title: "First", Body: "Body 1"},
+		{Number: "2", Title: "Second", Body: "Body 2"},
+		{Number: "5", Title: "Third", Body: "Body 5"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %+v", len(issues), len(want), issues)
+	}
+	for i := range want {
+		if issues[i].Number != want[i].Number || issues[i].Title != want[i].Title || issues[i].Body != want[i].Body {
+			t.Errorf("issue %d = %+v, want %+v", i, issues[i], want[i])
+		}
+	}
+}
+
+func TestFetchTasksError(t *testing.T) {
+	g := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+	}))
+
+	_, err := g.FetchTasks()
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("FetchTasks error = %v, want a 401 error", err)
+	}
+}
+
+func TestFetchAttachments(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	png := []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16))
+
+	g := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkRequest(t, r, http.MethodGet, "/uploads/"+secret+"/screen.png")
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+
+	// Relative upload URLs are how GitLab writes uploads into descriptions
+	absoluteURL := g.baseURL + "/" + testRepository + "/uploads/" + secret + "/screen.png"
+	body := fmt.Sprintf("Relative ![screen](/uploads/%s/screen.png)\nAbsolute ![screen](%s)", secret, absoluteURL)
+
+	// Uploads of other projects or hosts are left as they are
+	const otherSecret = "fedcba9876543210fedcba9876543210"
+	body += fmt.Sprintf("\nOther project ![x](%s/other/project/uploads/%s/x.png)", g.baseURL, otherSecret)
+	body += fmt.Sprintf("\nOther host ![x](https://gitlab.example.com/%s/uploads/%s/x.png)", testRepository, otherSecret)
+	body += fmt.Sprintf("\nOther site ![x](other/project/uploads/%s/x.png)", otherSecret)
+
+	dir := t.TempDir()
+	attachments, err := g.FetchAttachments(provider.Issue{Number: "1", Body: body}, dir)
+	if err != nil {
+		t.Fatalf("FetchAttachments: %v", err)
+	}
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2: %+v", len(attachments), attachments)
+	}
+
+	wantURLs := []string{"/uploads/" + secret + "/screen.png", absoluteURL}
+	for i, attachment := range attachments {
+		if attachment.URL != wantURLs[i] {
+			t.Errorf("attachment %d URL = %q, want %q", i, attachment.URL, wantURLs[i])
+		}
+		if filepath.Dir(attachment.Path) != dir || filepath.Ext(attachment.Path) != ".png" {
+			t.Errorf("attachment %d path = %q, want a .png file in %s", i, attachment.Path, dir)
+		}
+		data, err := os.ReadFile(attachment.Path)
+		if err != nil || string(data) != string(png) {
+			t.Errorf("attachment %d content = %q (%v), want the uploaded file", i, data, err)
+		}
+	}
+}
+
+func TestFetchAttachmentsNone(t *testing.T) {
+	g := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	}))
+
+	attachments, err := g.FetchAttachments(provider.Issue{Number: "1", Body: "![x](https://example.com/a.png)"}, t.TempDir())
+	if err != nil || len(attachments) != 0 {
+		t.Fatalf("FetchAttachments = %+v, %v, want nothing", attachments, err)
+	}
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		draft     bool
+		wantTitle string
+	}{
+		{name: "ready", draft: false, wantTitle: "aidd: [task_1] Add retry"},
+		{name: "draft", draft: true, wantTitle: "Draft: aidd: [task_1] Add retry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				checkRequest(t, r, http.MethodPost, "/merge_requests")
+
+				if err := r.ParseForm(); err != nil {
+					t.Fatalf("ParseForm: %v", err)
+				}
+				want := map[string]string{
+					"source_branch": "aidd/task_1",
+					"target_branch": "main",
+					"title":         tt.wantTitle,
+					"description":   "Task body",
+					"labels":        testLabel,
+				}
+				for key, value := range want {
+					if got := r.PostForm.Get(key); got != value {
+						t.Errorf("%s = %q, want %q", key, got, value)
+					}
+				}
+
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(GitLabMergeRequest{IID: 7, WebURL: "https://gitlab.example.com/group/project/-/merge_requests/7"})
+			}))
+
+			prURL, err := g.CreatePullRequest(testWorkspace{}, provider.PullRequest{
+				Base:  "main",
+				Head:  "aidd/task_1",
+				Title: "aidd: [task_1] Add retry",
+				Body:  "Task body",
+				Label: testLabel,
+				Draft: tt.draft,
+			})
+			if err != nil {
+				t.Fatalf("CreatePullRequest: %v", err)
+			}
+			if prURL != "https://gitlab.example.com/group/project/-/merge_requests/7" {
+				t.Errorf("URL = %q", prURL)
+			}
+		})
+	}
+}
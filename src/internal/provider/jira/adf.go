@@ -0,0 +1,259 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Node of an Atlassian Document Format document
+type adfNode struct {
+	Type    string         `json:"type"`
+	Text    string         `json:"text,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Marks   []adfMark      `json:"marks,omitempty"`
+	Content []adfNode      `json:"content,omitempty"`
+}
+
+type adfMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// Regex to find URLs in plain text comments
+var urlRegex = regexp.MustCompile(`https?://\S+`)
+
+// Convert a document to Markdown (embedded images are linked to contentURLs by file name)
+func adfToMarkdown(doc adfNode, contentURLs map[string]string) string {
+	var sb strings.Builder
+	renderADFBlocks(&sb, doc.Content, "", contentURLs)
+
+	return strings.TrimSpace(sb.String())
+}
+
+// Render block nodes (each followed by a blank line unless they are list items)
+func renderADFBlocks(sb *strings.Builder, nodes []adfNode, indent string, contentURLs map[string]string) {
+	for _, node := range nodes {
+		switch node.Type {
+		case "paragraph":
+			sb.WriteString(indent + renderADFInline(node.Content) + "\n\n")
+		case "heading":
+			level := 1
+			if l, ok := node.Attrs["level"].(float64); ok {
+				level = int(l)
+			}
+			sb.WriteString(indent + strings.Repeat("#", level) + " " + renderADFInline(node.Content) + "\n\n")
+		case "bulletList", "orderedList", "taskList":
+			renderADFList(sb, node, indent, contentURLs)
+			if indent == "" {
+				sb.WriteString("\n")
+			}
+		case "codeBlock":
+			language, _ := node.Attrs["language"].(string)
+			sb.WriteString(indent + "```" + language + "\n")
+			for _, line := range strings.Split(adfPlainText(node.Content), "\n") {
+				sb.WriteString(indent + line + "\n")
+			}
+			sb.WriteString(indent + "```\n\n")
+		case "blockquote", "panel":
+			var inner strings.Builder
+			renderADFBlocks(&inner, node.Content, "", contentURLs)
+			for _, line := range strings.Split(strings.TrimSpace(inner.String()), "\n") {
+				sb.WriteString(indent + "> " + line + "\n")
+			}
+			sb.WriteString("\n")
+		case "rule":
+			sb.WriteString(indent + "---\n\n")
+		case "mediaSingle", "mediaGroup":
+			for _, media := range node.Content {
+				sb.WriteString(indent + renderADFMedia(media, contentURLs) + "\n")
+			}
+			sb.WriteString("\n")
+		case "table":
+			renderADFTable(sb, node, indent)
+		default:
+			// Keep the content of unknown blocks
+			if len(node.Content) > 0 {
+				renderADFBlocks(sb, node.Content, indent, contentURLs)
+			} else if node.Text != "" {
+				sb.WriteString(indent + node.Text + "\n\n")
+			}
+		}
+	}
+}
+
+// Render the items of a list (nested lists are indented)
+func renderADFList(sb *strings.Builder, list adfNode, indent string, contentURLs map[string]string) {
+	for i, item := range list.Content {
+		marker := "- "
+		switch {
+		case list.Type == "orderedList":
+			marker = fmt.Sprintf("%d. ", i+1)
+		case item.Type == "taskItem":
+			if state, _ := item.Attrs["state"].(string); state == "DONE" {
+				marker = "- [x] "
+			} else {
+				marker = "- [ ] "
+			}
+		}
+
+		// Task items contain inline nodes, list items contain blocks
+		first := true
+		for _, child := range item.Content {
+			switch child.Type {
+			case "bulletList", "orderedList", "taskList":
+				renderADFList(sb, child, indent+strings.Repeat(" ", len(marker)), contentURLs)
+			case "paragraph":
+				sb.WriteString(listLine(indent, marker, first) + renderADFInline(child.Content) + "\n")
+				first = false
+			default:
+				if child.Text != "" || len(child.Marks) > 0 {
+					sb.WriteString(listLine(indent, marker, first) + renderADFInline([]adfNode{child}) + "\n")
+				} else {
+					var inner strings.Builder
+					renderADFBlocks(&inner, []adfNode{child}, "", contentURLs)
+					sb.WriteString(listLine(indent, marker, first) + strings.TrimSpace(inner.String()) + "\n")
+				}
+				first = false
+			}
+		}
+	}
+}
+
+// Prefix of a line of a list item (the marker only on its first line)
+func listLine(indent, marker string, first bool) string {
+	if first {
+		return indent + marker
+	}
+
+	return indent + strings.Repeat(" ", len(marker))
+}
+
+// Render a table as a Markdown table
+func renderADFTable(sb *strings.Builder, table adfNode, indent string) {
+	for i, row := range table.Content {
+		var cells []string
+		for _, cell := range row.Content {
+			var texts []string
+			for _, block := range cell.Content {
+				texts = append(texts, renderADFInline(block.Content))
+			}
+			cells = append(cells, strings.ReplaceAll(strings.Join(texts, " "), "|", "\\|"))
+		}
+
+		sb.WriteString(indent + "| " + strings.Join(cells, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString(indent + "|" + strings.Repeat(" --- |", len(cells)) + "\n")
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// Render a media node as an image linked to the attachment with the same file name
+func renderADFMedia(media adfNode, contentURLs map[string]string) string {
+	name, _ := media.Attrs["alt"].(string)
+	if contentURL, ok := contentURLs[name]; ok {
+		return fmt.Sprintf("![%s](%s)", name, contentURL)
+	}
+
+	// Media without a known file name is listed with the other attachments
+	return ""
+}
+
+// Render inline nodes as Markdown
+func renderADFInline(nodes []adfNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case "text":
+			sb.WriteString(applyADFMarks(node.Text, node.Marks))
+		case "hardBreak":
+			sb.WriteString("\n")
+		case "mention", "emoji", "status":
+			text, _ := node.Attrs["text"].(string)
+			if text == "" {
+				text, _ = node.Attrs["shortName"].(string)
+			}
+			sb.WriteString(text)
+		case "inlineCard", "blockCard":
+			link, _ := node.Attrs["url"].(string)
+			sb.WriteString(link)
+		default:
+			sb.WriteString(renderADFInline(node.Content))
+		}
+	}
+
+	return sb.String()
+}
+
+// Apply the marks of a text node (surrounding spaces are kept outside of the markers)
+func applyADFMarks(text string, marks []adfMark) string {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		return text
+	}
+	leading := text[:strings.Index(text, core)]
+	trailing := text[len(leading)+len(core):]
+
+	for _, mark := range marks {
+		switch mark.Type {
+		case "code":
+			core = "`" + core + "`"
+		case "strong":
+			core = "**" + core + "**"
+		case "em":
+			core = "*" + core + "*"
+		case "strike":
+			core = "~~" + core + "~~"
+		case "link":
+			href, _ := mark.Attrs["href"].(string)
+			core = fmt.Sprintf("[%s](%s)", core, href)
+		}
+	}
+
+	return leading + core + trailing
+}
+
+// Get the plain text of nodes
+func adfPlainText(nodes []adfNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		sb.WriteString(node.Text)
+		sb.WriteString(adfPlainText(node.Content))
+	}
+
+	return sb.String()
+}
+
+// Convert plain text to a document (one paragraph per line, URLs are linked)
+func textToADF(text string) map[string]any {
+	var paragraphs []adfNode
+	for _, line := range strings.Split(text, "\n") {
+		paragraph := adfNode{Type: "paragraph"}
+
+		last := 0
+		for _, loc := range urlRegex.FindAllStringIndex(line, -1) {
+			if loc[0] > last {
+				paragraph.Content = append(paragraph.Content, adfNode{Type: "text", Text: line[last:loc[0]]})
+			}
+			link := line[loc[0]:loc[1]]
+			paragraph.Content = append(paragraph.Content, adfNode{
+				Type:  "text",
+				Text:  link,
+				Marks: []adfMark{{Type: "link", Attrs: map[string]any{"href": link}}},
+			})
+			last = loc[1]
+		}
+		if last < len(line) {
+			paragraph.Content = append(paragraph.Content, adfNode{Type: "text", Text: line[last:]})
+		}
+
+		paragraphs = append(paragraphs, paragraph)
+	}
+
+	return map[string]any{
+		"version": 1,
+		"type":    "doc",
+		"content": paragraphs,
+	}
+}
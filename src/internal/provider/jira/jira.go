@@ -0,0 +1,374 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+	dl "github.com/tomoyuki65/go-aidd/internal/util/download"
+)
+
+// Version of the REST API used when jira.api_version is not set (Jira Cloud)
+const defaultAPIVersion = 3
+
+// Number of issues requested per page
+const pageSize = 50
+
+// Characters not allowed in the names of downloaded attachments
+var unsafeFileNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type JiraAttachment struct {
+	ID       string `json:"id"`
+	FileName string `json:"filename"`
+	Content  string `json:"content"`
+}
+
+type JiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		// Atlassian Document Format (API v3) or wiki markup (API v2)
+		Description json.RawMessage  `json:"description"`
+		Attachment  []JiraAttachment `json:"attachment"`
+	} `json:"fields"`
+}
+
+type JiraTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// Provider of tasks from Jira issues (uses the REST API)
+//
+// The issues matching the JQL query become tasks numbered by their key (e.g.
+// PROJ-123). Their descriptions are converted to Markdown and their
+// attachments are linked from the task body.
+type Jira struct {
+	baseURL     string
+	apiVersion  int
+	auth        string
+	jql         string
+	transitions map[string]string
+	client      *http.Client
+	download    config.DownloadConfig
+}
+
+func init() {
+	provider.Register("Jira", func(cfg *config.Config) (provider.Provider, error) {
+		return New(cfg)
+	})
+}
+
+// Create a provider for the issues matching the JQL query set in the config
+//
+// Jira Cloud is accessed with jira.email and an API token, Jira Data Center
+// with a personal access token (jira.email empty). The token is read from
+// jira.token, or from the JIRA_API_TOKEN environment variable if it is not set.
+func New(cfg *config.Config) (*Jira, error) {
+	if cfg.Jira.BaseURL == "" {
+		return nil, errors.New("jira.base_url is not set")
+	}
+
+	token := cfg.Jira.Token
+	if token == "" {
+		token = os.Getenv("JIRA_API_TOKEN")
+	}
+
+	auth := fmt.Sprintf("Bearer %s", token)
+	if cfg.Jira.Email != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Jira.Email+":"+token))
+	}
+
+	apiVersion := cfg.Jira.APIVersion
+	if apiVersion == 0 {
+		apiVersion = defaultAPIVersion
+	}
+
+	// Unresolved issues with the label by default
+	jql := cfg.Jira.JQL
+	if jql == "" {
+		jql = fmt.Sprintf("labels = %q AND resolution = Unresolved ORDER BY created ASC", cfg.Issue.Label)
+	}
+
+	return &Jira{
+		baseURL:     strings.TrimSuffix(cfg.Jira.BaseURL, "/"),
+		apiVersion:  apiVersion,
+		auth:        auth,
+		jql:         jql,
+		transitions: cfg.Jira.Transitions,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		download:    cfg.Download,
+	}, nil
+}
+
+// Fetch the issues matching the JQL query
+func (j *Jira) FetchTasks() ([]provider.Issue, error) {
+	jiraIssues, err := j.search(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch task information: %w", err)
+	}
+
+	issues := make([]provider.Issue, 0, len(jiraIssues))
+	for _, jiraIssue := range jiraIssues {
+		issues = append(issues, provider.Issue{
+			Number: jiraIssue.Key,
+			Title:  jiraIssue.Fields.Summary,
+			Body:   j.renderBody(jiraIssue),
+		})
+	}
+
+	return issues, nil
+}
+
+// Download the attachments linked from the task body into dir
+func (j *Jira) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	// Extract all attachment URLs from the task body
+	contentURLRegex := regexp.MustCompile(regexp.QuoteMeta(j.baseURL) + `/rest/api/\d+/attachment/content/(\d+)`)
+	urls := uniqueStrings(contentURLRegex.FindAllString(issue.Body, -1))
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	// Look up the file names of the attachments
+	var jiraIssue JiraIssue
+	endpoint := fmt.Sprintf("/issue/%s?fields=attachment", url.PathEscape(issue.Number))
+	if err := j.request(context.Background(), http.MethodGet, endpoint, nil, &jiraIssue); err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments of issue %s: %w", issue.Number, err)
+	}
+
+	fileNames := map[string]string{}
+	for _, attachment := range jiraIssue.Fields.Attachment {
+		fileNames[attachment.ID] = attachment.FileName
+	}
+
+	downloader, err := provider.NewDownloader(j.download)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the attachments in parallel (named after the original files)
+	header := http.Header{"Authorization": {j.auth}}
+	reqs := make([]dl.Request, 0, len(urls))
+	for i, contentURL := range urls {
+		id := contentURL[strings.LastIndex(contentURL, "/")+1:]
+		fileName := unsafeFileNameRegex.ReplaceAllString(fileNames[id], "_")
+		reqs = append(reqs, dl.Request{
+			URL:      contentURL,
+			Path:     filepath.Join(dir, fmt.Sprintf("%d_%s", i+1, strings.TrimSuffix(fileName, filepath.Ext(fileName)))),
+			FileName: fileName,
+			Header:   header,
+		})
+	}
+
+	return provider.DownloadAttachments(downloader, urls, reqs)
+}
+
+// Add a comment to the issue
+func (j *Jira) CommentOnTask(number, body string) error {
+	// API v3 takes the comment as a document, API v2 as wiki markup
+	var payload map[string]any
+	if j.apiVersion >= 3 {
+		payload = map[string]any{"body": textToADF(body)}
+	} else {
+		payload = map[string]any{"body": body}
+	}
+
+	endpoint := fmt.Sprintf("/issue/%s/comment", url.PathEscape(number))
+	if err := j.request(context.Background(), http.MethodPost, endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to comment on issue %s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Transition the issue as set in jira.transitions for the status
+//
+// Nothing is done if no transition is set for the status.
+func (j *Jira) UpdateTaskStatus(number, status string) error {
+	name := j.transitions[status]
+	if name == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	endpoint := fmt.Sprintf("/issue/%s/transitions", url.PathEscape(number))
+
+	// Find the transition by its name or the name of its target status
+	var result struct {
+		Transitions []JiraTransition `json:"transitions"`
+	}
+	if err := j.request(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return fmt.Errorf("failed to fetch transitions of issue %s: %w", number, err)
+	}
+
+	for _, transition := range result.Transitions {
+		if strings.EqualFold(transition.Name, name) || strings.EqualFold(transition.To.Name, name) {
+			payload := map[string]any{"transition": map[string]string{"id": transition.ID}}
+			if err := j.request(ctx, http.MethodPost, endpoint, payload, nil); err != nil {
+				return fmt.Errorf("failed to transition issue %s to '%s': %w", number, name, err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("transition '%s' is not available for issue %s", name, number)
+}
+
+// Search the issues matching the JQL query
+func (j *Jira) search(ctx context.Context) ([]JiraIssue, error) {
+	var issues []JiraIssue
+
+	params := url.Values{
+		"jql":        {j.jql},
+		"fields":     {"summary,description,attachment"},
+		"maxResults": {strconv.Itoa(pageSize)},
+	}
+
+	// Jira Cloud pages with a token, Jira Data Center with an offset
+	for {
+		var result struct {
+			Issues        []JiraIssue `json:"issues"`
+			Total         int         `json:"total"`
+			NextPageToken string      `json:"nextPageToken"`
+			IsLast        bool        `json:"isLast"`
+		}
+
+		endpoint := "/search?"
+		if j.apiVersion >= 3 {
+			endpoint = "/search/jql?"
+		}
+		if err := j.request(ctx, http.MethodGet, endpoint+params.Encode(), nil, &result); err != nil {
+			return nil, err
+		}
+		issues = append(issues, result.Issues...)
+
+		if j.apiVersion >= 3 {
+			if result.IsLast || result.NextPageToken == "" {
+				return issues, nil
+			}
+			params.Set("nextPageToken", result.NextPageToken)
+		} else {
+			if len(result.Issues) == 0 || len(issues) >= result.Total {
+				return issues, nil
+			}
+			params.Set("startAt", strconv.Itoa(len(issues)))
+		}
+	}
+}
+
+// Render the description of the issue as Markdown followed by the attachments not referenced in it
+func (j *Jira) renderBody(issue JiraIssue) string {
+	// URLs of the attachments by file name, used to resolve embedded images
+	contentURLs := map[string]string{}
+	for _, attachment := range issue.Fields.Attachment {
+		contentURLs[attachment.FileName] = j.contentURL(attachment.ID)
+	}
+
+	// The description is a document (API v3) or a string of wiki markup (API v2)
+	var body string
+	var wiki string
+	if err := json.Unmarshal(issue.Fields.Description, &wiki); err == nil {
+		body = wikiToMarkdown(wiki, contentURLs)
+	} else {
+		var doc adfNode
+		if err := json.Unmarshal(issue.Fields.Description, &doc); err == nil {
+			body = adfToMarkdown(doc, contentURLs)
+		}
+	}
+
+	// List the other attachments so that they are available to the AI tool as well
+	var others []string
+	for _, attachment := range issue.Fields.Attachment {
+		contentURL := contentURLs[attachment.FileName]
+		if !strings.Contains(body, contentURL) {
+			others = append(others, fmt.Sprintf("- [%s](%s)", attachment.FileName, contentURL))
+		}
+	}
+	if len(others) > 0 {
+		body = strings.TrimSpace(body + "\n\nAttachments:\n" + strings.Join(others, "\n"))
+	}
+
+	return body
+}
+
+// URL of the content of an attachment
+func (j *Jira) contentURL(id string) string {
+	return fmt.Sprintf("%s/rest/api/%d/attachment/content/%s", j.baseURL, j.apiVersion, id)
+}
+
+// Send a request to an endpoint of the REST API and decode the JSON response into out
+//
+// in is sent as the JSON request body when it is not nil.
+func (j *Jira) request(ctx context.Context, method, endpoint string, in, out any) error {
+	reqURL := fmt.Sprintf("%s/rest/api/%d%s", j.baseURL, j.apiVersion, endpoint)
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", j.auth)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status code %d %s: %s",
+			method, req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Remove duplicates while keeping the order
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,111 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Regexes of wiki markup (Jira Data Center / API v2)
+var (
+	wikiHeadingRegex   = regexp.MustCompile(`^h([1-6])\.\s+(.*)$`)
+	wikiListRegex      = regexp.MustCompile(`^([*#-]+)\s+(.*)$`)
+	wikiCodeStartRegex = regexp.MustCompile(`^\{(code|noformat)(?::([^}|]*))?[^}]*\}(.*)$`)
+	wikiImageRegex     = regexp.MustCompile(`!([^!|\s][^!|]*?)(?:\|[^!]*)?!`)
+	wikiLinkRegex      = regexp.MustCompile(`\[([^\]|]+)\|([^\]]+)\]`)
+	wikiBareLinkRegex  = regexp.MustCompile(`\[((?:https?|mailto):[^\]]+)\]`)
+	wikiMonoRegex      = regexp.MustCompile(`\{\{(.+?)\}\}`)
+	wikiBoldRegex      = regexp.MustCompile(`(^|[^\w*])\*(\S(?:[^*]*\S)?)\*([^\w*]|$)`)
+	wikiItalicRegex    = regexp.MustCompile(`(^|[^\w_])_(\S(?:[^_]*\S)?)_([^\w_]|$)`)
+	wikiStrikeRegex    = regexp.MustCompile(`(^|[^\w-])-(\S(?:[^-]*\S)?)-([^\w-]|$)`)
+)
+
+// Convert wiki markup to Markdown (embedded images are linked to contentURLs by file name)
+func wikiToMarkdown(wiki string, contentURLs map[string]string) string {
+	var lines []string
+	var codeEnd string
+
+	for _, line := range strings.Split(strings.ReplaceAll(wiki, "\r\n", "\n"), "\n") {
+		// Copy the lines of code blocks as they are
+		if codeEnd != "" {
+			if before, found := strings.CutSuffix(line, codeEnd); found {
+				if before != "" {
+					lines = append(lines, before)
+				}
+				lines = append(lines, "```")
+				codeEnd = ""
+			} else {
+				lines = append(lines, line)
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+
+		if m := wikiCodeStartRegex.FindStringSubmatch(trimmed); m != nil {
+			lines = append(lines, "```"+m[2])
+			codeEnd = "{" + m[1] + "}"
+			if rest, found := strings.CutSuffix(m[3], codeEnd); found {
+				// Code block on a single line
+				if rest != "" {
+					lines = append(lines, rest)
+				}
+				lines = append(lines, "```")
+				codeEnd = ""
+			} else if m[3] != "" {
+				lines = append(lines, m[3])
+			}
+			continue
+		}
+
+		switch {
+		case wikiHeadingRegex.MatchString(trimmed):
+			m := wikiHeadingRegex.FindStringSubmatch(trimmed)
+			lines = append(lines, strings.Repeat("#", int(m[1][0]-'0'))+" "+wikiInline(m[2], contentURLs))
+		case strings.HasPrefix(trimmed, "bq. "):
+			lines = append(lines, "> "+wikiInline(strings.TrimPrefix(trimmed, "bq. "), contentURLs))
+		case trimmed == "----":
+			lines = append(lines, "---")
+		case wikiListRegex.MatchString(trimmed) && !strings.HasPrefix(trimmed, "--"):
+			m := wikiListRegex.FindStringSubmatch(trimmed)
+			indent := strings.Repeat("  ", len(m[1])-1)
+			marker := "- "
+			if strings.HasSuffix(m[1], "#") {
+				marker = "1. "
+			}
+			lines = append(lines, indent+marker+wikiInline(m[2], contentURLs))
+		default:
+			lines = append(lines, wikiInline(line, contentURLs))
+		}
+	}
+
+	// Close a code block that is not terminated
+	if codeEnd != "" {
+		lines = append(lines, "```")
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Convert the inline markup of a line
+func wikiInline(text string, contentURLs map[string]string) string {
+	// Images embedded from attachments (!file.png|thumbnail!)
+	text = wikiImageRegex.ReplaceAllStringFunc(text, func(s string) string {
+		name := wikiImageRegex.FindStringSubmatch(s)[1]
+		if contentURL, ok := contentURLs[name]; ok {
+			return fmt.Sprintf("![%s](%s)", name, contentURL)
+		} else if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+			return fmt.Sprintf("![](%s)", name)
+		}
+		return s
+	})
+
+	text = wikiLinkRegex.ReplaceAllString(text, "[$1]($2)")
+	text = wikiBareLinkRegex.ReplaceAllString(text, "<$1>")
+	text = wikiMonoRegex.ReplaceAllString(text, "`$1`")
+	text = wikiBoldRegex.ReplaceAllString(text, "$1**$2**$3")
+	text = wikiItalicRegex.ReplaceAllString(text, "$1*$2*$3")
+	text = wikiStrikeRegex.ReplaceAllString(text, "$1~~$2~~$3")
+
+	return text
+}
@@ -0,0 +1,209 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+)
+
+// Directory of the task files used when local.dir is not set
+const defaultDir = "tasks"
+
+// Delimiter of the YAML front matter
+const frontMatterDelimiter = "---"
+
+// Regex to get the number from the file name (e.g. 0012 of 0012-add-retry.md)
+var fileNumberRegex = regexp.MustCompile(`^(\d+)`)
+
+// Regex to get the title from the first heading of the body
+var headingRegex = regexp.MustCompile(`(?m)^#\s+(.+)$`)
+
+// Front matter of a task file
+type FrontMatter struct {
+	Number string   `yaml:"number"`
+	Title  string   `yaml:"title"`
+	Labels []string `yaml:"labels"`
+	AI     struct {
+		Type  string `yaml:"type"`
+		Model string `yaml:"model"`
+	} `yaml:"ai"`
+	DependsOn  []string `yaml:"depends_on"`
+	Status     string   `yaml:"status"`
+	Priority   string   `yaml:"priority"`
+	BaseBranch string   `yaml:"base_branch"`
+}
+
+// Provider of tasks from a directory of Markdown files
+//
+// Each file (e.g. tasks/0012-add-retry.md) is a task: the YAML front matter
+// holds its metadata and the rest of the file is the task body. The tasks are
+// read directly from the files, so task.md is not used.
+type Local struct {
+	dir   string
+	label string
+}
+
+func init() {
+	provider.Register("local", func(cfg *config.Config) (provider.Provider, error) {
+		return New(cfg), nil
+	})
+}
+
+// Create a provider for the directory set in the config
+func New(cfg *config.Config) *Local {
+	dir := cfg.Local.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+
+	return &Local{
+		dir:   dir,
+		label: cfg.Issue.Label,
+	}
+}
+
+// The tasks are read from the files, so task.md is not generated
+func (l *Local) FetchTasks() ([]provider.Issue, error) {
+	return nil, provider.ErrTaskMdNotManaged
+}
+
+// Load the tasks from the Markdown files in the directory (in file name order)
+//
+// Tasks with labels are only loaded if issue.label is one of them.
+func (l *Local) LoadTasks() ([]provider.Issue, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task files: %w", err)
+	} else if len(paths) == 0 {
+		return nil, fmt.Errorf("no task files found in %s: %w", l.dir, os.ErrNotExist)
+	}
+	sort.Strings(paths)
+
+	var issues []provider.Issue
+	files := map[string]string{}
+	for _, path := range paths {
+		if strings.EqualFold(filepath.Base(path), "README.md") {
+			continue
+		}
+
+		issue, err := loadTaskFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(issue.Labels) > 0 && l.label != "" && !slices.Contains(issue.Labels, l.label) {
+			continue
+		}
+
+		if other, exists := files[issue.Number]; exists {
+			return nil, fmt.Errorf("task number %s is used by both %s and %s", issue.Number, other, path)
+		}
+		files[issue.Number] = path
+
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+// The task body is read as it is, so there is nothing to download
+func (l *Local) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	return nil, nil
+}
+
+// There is no issue to comment on for tasks in files
+func (l *Local) CommentOnTask(number, body string) error {
+	return nil
+}
+
+// There is no issue to update for tasks in files
+func (l *Local) UpdateTaskStatus(number, status string) error {
+	return nil
+}
+
+// Load a task from a Markdown file with optional YAML front matter
+func loadTaskFile(path string) (provider.Issue, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return provider.Issue{}, fmt.Errorf("failed to read task file: %w", err)
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+
+	var fm FrontMatter
+	body := string(content)
+
+	// Split the front matter from the body
+	if rest, found := strings.CutPrefix(body, frontMatterDelimiter+"\n"); found {
+		header, after, found := strings.Cut(rest, "\n"+frontMatterDelimiter)
+		if !found {
+			return provider.Issue{}, fmt.Errorf("front matter of %s is not closed", path)
+		}
+		if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+			return provider.Issue{}, fmt.Errorf("failed to parse front matter of %s: %w", path, err)
+		}
+		body = after[strings.IndexByte(after+"\n", '\n')+1:]
+	}
+	body = strings.TrimSpace(body)
+
+	// Use the number of the file name if the front matter has none
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	number := fm.Number
+	if number == "" {
+		number = name
+		if m := fileNumberRegex.FindString(name); m != "" {
+			number = m
+		}
+	}
+	number = normalizeNumber(number)
+
+	// Use the first heading or the file name if the front matter has no title
+	title := fm.Title
+	if title == "" {
+		if m := headingRegex.FindStringSubmatch(body); m != nil {
+			title = strings.TrimSpace(m[1])
+		} else {
+			title = name
+		}
+	}
+
+	dependsOn := make([]string, 0, len(fm.DependsOn))
+	for _, dep := range fm.DependsOn {
+		// Dependencies can be written as issue references (e.g. "#12")
+		dependsOn = append(dependsOn, normalizeNumber(strings.TrimPrefix(strings.TrimSpace(dep), "#")))
+	}
+
+	return provider.Issue{
+		Number:     number,
+		Title:      title,
+		Body:       body,
+		Labels:     fm.Labels,
+		AIType:     fm.AI.Type,
+		AIModel:    fm.AI.Model,
+		DependsOn:  dependsOn,
+		Status:     fm.Status,
+		Priority:   fm.Priority,
+		BaseBranch: fm.BaseBranch,
+		Dir:        filepath.Dir(path),
+	}, nil
+}
+
+// Remove the leading zeros of numeric task numbers (e.g. 0012 -> 12)
+func normalizeNumber(number string) string {
+	number = strings.TrimSpace(number)
+	if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+		return strconv.Itoa(n)
+	}
+
+	return number
+}
@@ -0,0 +1,235 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Rich text of a block or property
+type RichText struct {
+	PlainText   string `json:"plain_text"`
+	Href        string `json:"href"`
+	Annotations struct {
+		Bold          bool `json:"bold"`
+		Italic        bool `json:"italic"`
+		Strikethrough bool `json:"strikethrough"`
+		Code          bool `json:"code"`
+	} `json:"annotations"`
+}
+
+// Block of a page (the content is stored under the key named by Type)
+type NotionBlock struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	HasChildren bool   `json:"has_children"`
+	content     blockContent
+}
+
+// Content of a block (only the fields used to render Markdown)
+type blockContent struct {
+	RichText []RichText `json:"rich_text"`
+	Language string     `json:"language"`
+	Checked  bool       `json:"checked"`
+	Caption  []RichText `json:"caption"`
+	File     *struct {
+		URL string `json:"url"`
+	} `json:"file"`
+	External *struct {
+		URL string `json:"url"`
+	} `json:"external"`
+}
+
+func (b *NotionBlock) UnmarshalJSON(data []byte) error {
+	type block NotionBlock
+	if err := json.Unmarshal(data, (*block)(b)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields[b.Type]; ok {
+		return json.Unmarshal(raw, &b.content)
+	}
+
+	return nil
+}
+
+// Render the blocks of the page as Markdown
+func (n *Notion) renderPage(ctx context.Context, pageID string) (string, error) {
+	var lines []string
+	if err := n.renderBlocks(ctx, pageID, "", &lines); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// Render the children of the block into lines (nested list items are indented)
+func (n *Notion) renderBlocks(ctx context.Context, blockID, indent string, lines *[]string) error {
+	blocks, err := n.fetchChildren(ctx, blockID)
+	if err != nil {
+		return err
+	}
+
+	number := 0
+	for _, block := range blocks {
+		c := block.content
+		text := markdownText(c.RichText)
+
+		// Number the items of each numbered list from 1
+		if block.Type == "numbered_list_item" {
+			number++
+		} else {
+			number = 0
+		}
+
+		childIndent := indent
+		switch block.Type {
+		case "paragraph":
+			*lines = append(*lines, indent+text)
+		case "heading_1":
+			*lines = append(*lines, indent+"# "+text)
+		case "heading_2":
+			*lines = append(*lines, indent+"## "+text)
+		case "heading_3":
+			*lines = append(*lines, indent+"### "+text)
+		case "bulleted_list_item", "toggle":
+			*lines = append(*lines, indent+"- "+text)
+			childIndent = indent + "  "
+		case "numbered_list_item":
+			*lines = append(*lines, fmt.Sprintf("%s%d. %s", indent, number, text))
+			childIndent = indent + "   "
+		case "to_do":
+			check := " "
+			if c.Checked {
+				check = "x"
+			}
+			*lines = append(*lines, fmt.Sprintf("%s- [%s] %s", indent, check, text))
+			childIndent = indent + "  "
+		case "quote", "callout":
+			*lines = append(*lines, indent+"> "+text)
+		case "code":
+			*lines = append(*lines, indent+"```"+c.Language)
+			for _, line := range strings.Split(plainText(c.RichText), "\n") {
+				*lines = append(*lines, indent+line)
+			}
+			*lines = append(*lines, indent+"```")
+		case "image":
+			imageURL := ""
+			if c.File != nil {
+				imageURL = c.File.URL
+			} else if c.External != nil {
+				imageURL = c.External.URL
+			}
+			*lines = append(*lines, fmt.Sprintf("%s![%s](%s)", indent, plainText(c.Caption), imageURL))
+		case "divider":
+			*lines = append(*lines, indent+"---")
+		default:
+			// Keep the text of other blocks that have any
+			if text != "" {
+				*lines = append(*lines, indent+text)
+			}
+		}
+
+		if block.HasChildren && block.Type != "child_page" && block.Type != "child_database" {
+			if err := n.renderBlocks(ctx, block.ID, childIndent, lines); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Fetch all children of the block
+func (n *Notion) fetchChildren(ctx context.Context, blockID string) ([]NotionBlock, error) {
+	var blocks []NotionBlock
+
+	cursor := ""
+	for {
+		endpoint := fmt.Sprintf("/v1/blocks/%s/children?page_size=100", blockID)
+		if cursor != "" {
+			endpoint += "&start_cursor=" + cursor
+		}
+
+		var result struct {
+			Results    []NotionBlock `json:"results"`
+			HasMore    bool          `json:"has_more"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := n.request(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, result.Results...)
+		if !result.HasMore {
+			return blocks, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// Render rich text as Markdown
+func markdownText(richText []RichText) string {
+	var sb strings.Builder
+	for _, rt := range richText {
+		// Keep surrounding spaces outside of the emphasis markers
+		text := strings.TrimSpace(rt.PlainText)
+		if text == "" {
+			sb.WriteString(rt.PlainText)
+			continue
+		}
+		leading := rt.PlainText[:strings.Index(rt.PlainText, text)]
+		trailing := rt.PlainText[len(leading)+len(text):]
+
+		if rt.Annotations.Code {
+			text = "`" + text + "`"
+		}
+		if rt.Annotations.Bold {
+			text = "**" + text + "**"
+		}
+		if rt.Annotations.Italic {
+			text = "*" + text + "*"
+		}
+		if rt.Annotations.Strikethrough {
+			text = "~~" + text + "~~"
+		}
+		if rt.Href != "" {
+			text = fmt.Sprintf("[%s](%s)", text, rt.Href)
+		}
+		sb.WriteString(leading + text + trailing)
+	}
+
+	return sb.String()
+}
+
+// Get the plain text of rich text
+func plainText(richText []RichText) string {
+	var sb strings.Builder
+	for _, rt := range richText {
+		sb.WriteString(rt.PlainText)
+	}
+
+	return sb.String()
+}
+
+// Create rich text from a string (split into chunks accepted by the API)
+func plainRichText(text string) []map[string]any {
+	var richText []map[string]any
+	runes := []rune(text)
+	for len(runes) > 0 {
+		size := min(len(runes), maxRichTextLength)
+		richText = append(richText, map[string]any{
+			"type": "text",
+			"text": map[string]string{"content": string(runes[:size])},
+		})
+		runes = runes[size:]
+	}
+
+	return richText
+}
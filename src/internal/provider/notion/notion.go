@@ -0,0 +1,459 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+	"github.com/tomoyuki65/go-aidd/internal/provider"
+	dl "github.com/tomoyuki65/go-aidd/internal/util/download"
+)
+
+// Endpoint of the Notion API used when notion.base_url is not set
+const defaultBaseURL = "https://api.notion.com"
+
+// Version of the Notion API
+const notionVersion = "2022-06-28"
+
+// Default names of the database properties
+const (
+	defaultFilterProperty = "Status"
+	defaultNumberProperty = "ID"
+)
+
+// Maximum length of a rich text object accepted by the Notion API
+const maxRichTextLength = 2000
+
+// Regex to extract images from task bodies (e.g. ![caption](https://...))
+var imageRegex = regexp.MustCompile(`!\[[^\]]*\]\((https?://[^\s\)]+)\)`)
+
+// Values written to the status property of a page
+var statusValues = map[string]string{
+	provider.StatusRunning: "aidd: running",
+	provider.StatusDone:    "aidd: done",
+	provider.StatusFailed:  "aidd: failed",
+}
+
+// Property of a database (schema)
+type NotionPropertySchema struct {
+	Type     string `json:"type"`
+	UniqueID *struct {
+		Prefix string `json:"prefix"`
+	} `json:"unique_id"`
+}
+
+// Property of a page (value)
+type NotionProperty struct {
+	Type     string     `json:"type"`
+	Title    []RichText `json:"title"`
+	Number   *float64   `json:"number"`
+	UniqueID *struct {
+		Prefix string `json:"prefix"`
+		Number int    `json:"number"`
+	} `json:"unique_id"`
+}
+
+type NotionPage struct {
+	ID         string                    `json:"id"`
+	Properties map[string]NotionProperty `json:"properties"`
+}
+
+// Provider of tasks from the pages of a Notion database (uses the Notion API)
+//
+// Each page whose filter property matches the filter value becomes a task. The
+// task number is read from the number property (a unique ID such as "TASK-12"
+// or a number property) and the body is rendered from the blocks of the page as Markdown.
+type Notion struct {
+	baseURL        string
+	token          string
+	databaseID     string
+	filterProperty string
+	filterValue    string
+	numberProperty string
+	statusProperty string
+	client         *http.Client
+	download       config.DownloadConfig
+
+	// Properties of the database (fetched once)
+	schema map[string]NotionPropertySchema
+}
+
+func init() {
+	provider.Register("Notion", func(cfg *config.Config) (provider.Provider, error) {
+		return New(cfg)
+	})
+}
+
+// Create a provider for the database set in the config
+//
+// The integration token is read from notion.token, or from the NOTION_TOKEN
+// environment variable if it is not set.
+func New(cfg *config.Config) (*Notion, error) {
+	if cfg.Notion.DatabaseID == "" {
+		return nil, errors.New("notion.database_id is not set")
+	}
+
+	n := &Notion{
+		baseURL:        strings.TrimSuffix(cfg.Notion.BaseURL, "/"),
+		token:          cfg.Notion.Token,
+		databaseID:     cfg.Notion.DatabaseID,
+		filterProperty: cfg.Notion.FilterProperty,
+		filterValue:    cfg.Notion.FilterValue,
+		numberProperty: cfg.Notion.NumberProperty,
+		statusProperty: cfg.Notion.StatusProperty,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		download:       cfg.Download,
+	}
+
+	if n.baseURL == "" {
+		n.baseURL = defaultBaseURL
+	}
+	if n.token == "" {
+		n.token = os.Getenv("NOTION_TOKEN")
+	}
+	if n.filterProperty == "" {
+		n.filterProperty = defaultFilterProperty
+	}
+	if n.filterValue == "" {
+		n.filterValue = cfg.Issue.Label
+	}
+	if n.numberProperty == "" {
+		n.numberProperty = defaultNumberProperty
+	}
+
+	return n, nil
+}
+
+// Fetch the pages whose filter property matches the filter value
+func (n *Notion) FetchTasks() ([]provider.Issue, error) {
+	ctx := context.Background()
+
+	filter, err := n.propertyFilter(ctx, n.filterProperty, n.filterValue)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := n.queryDatabase(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch task information: %w", err)
+	}
+
+	issues := make([]provider.Issue, 0, len(pages))
+	for _, page := range pages {
+		number, err := n.pageNumber(page)
+		if err != nil {
+			return nil, err
+		}
+
+		// Render the blocks of the page as the task body
+		body, err := n.renderPage(ctx, page.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the content of task %s: %w", number, err)
+		}
+
+		issues = append(issues, provider.Issue{
+			Number: number,
+			Title:  pageTitle(page),
+			Body:   body,
+		})
+	}
+
+	return issues, nil
+}
+
+// Download the images in the task body into dir
+//
+// Files uploaded to Notion are served from signed URLs, so no token is needed.
+func (n *Notion) FetchAttachments(issue provider.Issue, dir string) ([]provider.Attachment, error) {
+	// Extract all image URLs from the task body
+	matches := imageRegex.FindAllStringSubmatch(issue.Body, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	downloader, err := provider.NewDownloader(n.download)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the images in parallel
+	urls := make([]string, 0, len(matches))
+	reqs := make([]dl.Request, 0, len(matches))
+	for i, match := range matches {
+		urls = append(urls, match[1])
+		reqs = append(reqs, dl.Request{URL: match[1], Path: filepath.Join(dir, fmt.Sprintf("img_%d", i+1))})
+	}
+
+	return provider.DownloadAttachments(downloader, urls, reqs)
+}
+
+// Add a comment to the page of the task
+func (n *Notion) CommentOnTask(number, body string) error {
+	ctx := context.Background()
+
+	pageID, err := n.findPage(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"parent":    map[string]string{"page_id": pageID},
+		"rich_text": plainRichText(body),
+	}
+	if err := n.request(ctx, http.MethodPost, "/v1/comments", payload, nil); err != nil {
+		return fmt.Errorf("failed to comment on task %s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Write the status of the task to the status property of the page
+func (n *Notion) UpdateTaskStatus(number, status string) error {
+	value, ok := statusValues[status]
+	if !ok {
+		return fmt.Errorf("unsupported task status: %s", status)
+	}
+	if n.statusProperty == "" {
+		return errors.New("notion.status_property is not set")
+	}
+
+	ctx := context.Background()
+
+	// Select options are created automatically, status options must exist in the database
+	schema, err := n.propertySchema(ctx, n.statusProperty)
+	if err != nil {
+		return err
+	}
+	if schema.Type != "select" && schema.Type != "status" {
+		return fmt.Errorf("unsupported type of property '%s': %s", n.statusProperty, schema.Type)
+	}
+
+	pageID, err := n.findPage(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"properties": map[string]any{
+			n.statusProperty: map[string]any{
+				schema.Type: map[string]string{"name": value},
+			},
+		},
+	}
+	if err := n.request(ctx, http.MethodPatch, "/v1/pages/"+pageID, payload, nil); err != nil {
+		return fmt.Errorf("failed to update the status of task %s: %w", number, err)
+	}
+
+	return nil
+}
+
+// Find the ID of the page with the task number
+//
+// The number is converted to the type of the number property (e.g. 12 of
+// "TASK-12" for a unique ID with the prefix TASK, or 1.5 for a number) so
+// that the query only returns the page of the task.
+func (n *Notion) findPage(ctx context.Context, number string) (string, error) {
+	schema, err := n.propertySchema(ctx, n.numberProperty)
+	if err != nil {
+		return "", err
+	}
+
+	var value any
+	switch schema.Type {
+	case "unique_id":
+		digits := number
+		if schema.UniqueID != nil && schema.UniqueID.Prefix != "" {
+			var ok bool
+			if digits, ok = strings.CutPrefix(number, schema.UniqueID.Prefix+"-"); !ok {
+				return "", fmt.Errorf("invalid task number %q: expected the prefix %s-", number, schema.UniqueID.Prefix)
+			}
+		}
+		id, err := strconv.Atoi(digits)
+		if err != nil || id < 0 {
+			return "", fmt.Errorf("invalid task number %q: expected a unique ID", number)
+		}
+		value = id
+	case "number":
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("invalid task number %q: expected a number", number)
+		}
+		value = f
+	default:
+		return "", fmt.Errorf("unsupported type of property '%s': %s", n.numberProperty, schema.Type)
+	}
+
+	pages, err := n.queryDatabase(ctx, map[string]any{
+		"property":  n.numberProperty,
+		schema.Type: map[string]any{"equals": value},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find task %s: %w", number, err)
+	} else if len(pages) == 0 {
+		return "", fmt.Errorf("task %s not found in the database", number)
+	}
+
+	return pages[0].ID, nil
+}
+
+// Create the filter of a database query matching the value of the property
+func (n *Notion) propertyFilter(ctx context.Context, name, value string) (map[string]any, error) {
+	schema, err := n.propertySchema(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var condition map[string]string
+	switch schema.Type {
+	case "status", "select":
+		condition = map[string]string{"equals": value}
+	case "multi_select":
+		condition = map[string]string{"contains": value}
+	default:
+		return nil, fmt.Errorf("unsupported type of property '%s': %s", name, schema.Type)
+	}
+
+	return map[string]any{
+		"property":  name,
+		schema.Type: condition,
+	}, nil
+}
+
+// Get the schema of a property of the database
+func (n *Notion) propertySchema(ctx context.Context, name string) (NotionPropertySchema, error) {
+	if n.schema == nil {
+		var database struct {
+			Properties map[string]NotionPropertySchema `json:"properties"`
+		}
+		if err := n.request(ctx, http.MethodGet, "/v1/databases/"+n.databaseID, nil, &database); err != nil {
+			return NotionPropertySchema{}, fmt.Errorf("failed to fetch the database: %w", err)
+		}
+		n.schema = database.Properties
+	}
+
+	schema, ok := n.schema[name]
+	if !ok {
+		return NotionPropertySchema{}, fmt.Errorf("property '%s' not found in the database", name)
+	}
+
+	return schema, nil
+}
+
+// Query all pages of the database matching the filter
+func (n *Notion) queryDatabase(ctx context.Context, filter map[string]any) ([]NotionPage, error) {
+	var pages []NotionPage
+
+	cursor := ""
+	for {
+		payload := map[string]any{
+			"filter":    filter,
+			"page_size": 100,
+		}
+		if cursor != "" {
+			payload["start_cursor"] = cursor
+		}
+
+		var result struct {
+			Results    []NotionPage `json:"results"`
+			HasMore    bool         `json:"has_more"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		endpoint := fmt.Sprintf("/v1/databases/%s/query", n.databaseID)
+		if err := n.request(ctx, http.MethodPost, endpoint, payload, &result); err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, result.Results...)
+		if !result.HasMore {
+			return pages, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// Get the task number of the page from the number property
+func (n *Notion) pageNumber(page NotionPage) (string, error) {
+	prop, ok := page.Properties[n.numberProperty]
+	if !ok {
+		return "", fmt.Errorf("property '%s' not found in page %s", n.numberProperty, page.ID)
+	}
+
+	switch {
+	case prop.UniqueID != nil:
+		if prop.UniqueID.Prefix != "" {
+			return fmt.Sprintf("%s-%d", prop.UniqueID.Prefix, prop.UniqueID.Number), nil
+		}
+		return strconv.Itoa(prop.UniqueID.Number), nil
+	case prop.Number != nil:
+		return strconv.FormatFloat(*prop.Number, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("property '%s' of page %s has no number", n.numberProperty, page.ID)
+	}
+}
+
+// Get the title of the page
+func pageTitle(page NotionPage) string {
+	for _, prop := range page.Properties {
+		if prop.Type == "title" {
+			return plainText(prop.Title)
+		}
+	}
+
+	return ""
+}
+
+// Send a request to the Notion API and decode the JSON response into out
+//
+// in is sent as the JSON request body when it is not nil.
+func (n *Notion) request(ctx context.Context, method, endpoint string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, n.baseURL+endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", n.token))
+	req.Header.Set("Notion-Version", notionVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status code %d %s: %s",
+			method, req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode), strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tomoyuki65/go-aidd/internal/config"
+)
+
+// Status of a task reported back to the provider
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Returned by FetchTasks of providers that don't generate task.md
+// (e.g. a hand-written task.md is used for local development)
+var ErrTaskMdNotManaged = errors.New("provider does not generate task.md")
+
+// Task retrieved from a provider (e.g. a GitHub issue)
+type Issue struct {
+	// Number or key of the task (e.g. "123" or "PROJ-123")
+	Number string
+	Title  string
+	Body   string
+	// Optional metadata (set by providers that support it)
+	Labels []string
+	// AI tool and model overriding the ai settings for the task
+	AIType  string
+	AIModel string
+	// Numbers of the tasks that must be completed before the task
+	DependsOn []string
+	// Progress of the task (todo, running, done or failed)
+	Status string
+	// Priority of the task (e.g. 1, P1 or high)
+	Priority string
+	// Branch the task branch is created from instead of repository.clone_branch
+	BaseBranch string
+	// Directory relative paths in the body are resolved against (e.g. of the task file)
+	Dir string
+}
+
+// Attachment of a task (e.g. an image) saved to a local file
+type Attachment struct {
+	// URL of the attachment as it appears in the task body
+	URL string
+	// Local path the attachment was saved to
+	Path string
+}
+
+// Source of tasks
+//
+// Providers register a Factory under the name used for issue.provider in
+// config.yml, so new sources can be added without changing the task module.
+type Provider interface {
+	// Fetch the tasks to be written to task.md
+	FetchTasks() ([]Issue, error)
+	// Download the attachments referenced in the body of the task into dir
+	// (when some downloads fail, the saved ones are returned with a *download.Error)
+	FetchAttachments(issue Issue, dir string) ([]Attachment, error)
+	// Add a comment to the task
+	CommentOnTask(number, body string) error
+	// Update the status of the task (StatusRunning, StatusDone or StatusFailed)
+	UpdateTaskStatus(number, status string) error
+}
+
+// Provider that holds the tasks itself instead of generating task.md
+//
+// The tasks of a Loader are read directly from the provider whenever they are
+// listed, so their bodies are not limited by the table format of task.md.
+type Loader interface {
+	// Load the tasks to be executed
+	LoadTasks() ([]Issue, error)
+}
+
+// Create a provider from the configuration
+type Factory func(cfg *config.Config) (Provider, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register a provider under the given name (called from the init function of the provider package)
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("provider %q is already registered", name))
+	}
+	factories[name] = factory
+}
+
+// Create the provider set in issue.provider
+func New(cfg *config.Config) (Provider, error) {
+	mu.RLock()
+	factory, ok := factories[cfg.Issue.Provider]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider is set: %q (available: %s)", cfg.Issue.Provider, strings.Join(Names(), ", "))
+	}
+
+	return factory(cfg)
+}
+
+// Get the names of the registered providers
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
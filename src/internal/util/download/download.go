@@ -1,50 +1,420 @@
 package download
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomoyuki65/go-aidd/internal/util/size"
+)
+
+// Default settings (download section of config.yml)
+const (
+	DefaultConcurrency = 4
+	DefaultRetries     = 3
+	DefaultMaxSize     = 100 << 20
+	DefaultTimeout     = 2 * time.Minute
 )
 
-// Save downloaded image to "images" directory
-func SaveImages(provider, url, token, filePath string) error {
-	// Request configuration
-	req, err := http.NewRequest("GET", url, nil)
+// First wait before retrying a failed download (doubled for each retry)
+const retryBackoff = time.Second
+
+// Longest wait before retrying, even if the server asks for more (Retry-After)
+const maxRetryWait = 30 * time.Second
+
+// Settings of a Downloader (0 means the default)
+type Options struct {
+	// Number of files downloaded at the same time
+	Concurrency int
+	// Number of retries of a download that failed with a network error, 429 or 5xx
+	Retries int
+	// Maximum size of a file in bytes
+	MaxSize int64
+	// Timeout of a request (including reading the body)
+	Timeout time.Duration
+}
+
+// File to download
+type Request struct {
+	URL string
+	// Path of the file without the extension (e.g. images/issue_1/img_1)
+	Path string
+	// Original name of the file if known; its extension is used instead of the content type
+	FileName string
+	// Headers of the request (e.g. authentication)
+	Header http.Header
+}
+
+// Result of a download
+type Result struct {
+	Request
+	// Path the file was saved to (Request.Path with the extension)
+	SavedPath string
+	// The file was already saved by a previous download and has not changed
+	Cached bool
+	Err    error
+}
+
+// Error reporting the downloads that failed (the other files were saved)
+type Error struct {
+	Failed []Result
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		messages = append(messages, r.Err.Error())
+	}
+
+	return fmt.Sprintf("failed to download %d file(s): %s", len(e.Failed), strings.Join(messages, "; "))
+}
+
+// Downloads files in parallel with retries and a cache
+//
+// The ETag, Last-Modified and SHA-256 of the saved files are recorded in a
+// manifest in each destination directory, so files that have not changed are
+// not downloaded or written again when task.md is regenerated.
+type Downloader struct {
+	client *http.Client
+	opts   Options
+
+	mu        sync.Mutex
+	manifests map[string]*manifest
+}
+
+func New(opts Options) *Downloader {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = DefaultRetries
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	return &Downloader{
+		client:    &http.Client{Timeout: opts.Timeout},
+		opts:      opts,
+		manifests: map[string]*manifest{},
+	}
+}
+
+// Download the files (results are in the order of reqs)
+//
+// A failed download doesn't stop the others. Use Saved to split the results.
+func (d *Downloader) Download(ctx context.Context, reqs []Request) []Result {
+	results := make([]Result, len(reqs))
+	sem := make(chan struct{}, d.opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = d.download(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	// Record the saved files for the next run
+	var errs []error
+	for dir, m := range d.manifests {
+		if err := m.save(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save download cache of %s: %w", dir, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+	}
+
+	return results
+}
+
+// Split the results into the saved files and an *Error of the failed downloads (nil if none failed)
+func Saved(results []Result) ([]Result, error) {
+	var saved, failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		} else {
+			saved = append(saved, r)
+		}
+	}
+	if len(failed) > 0 {
+		return saved, &Error{Failed: failed}
+	}
+
+	return saved, nil
+}
+
+// Download a file with retries
+func (d *Downloader) download(ctx context.Context, req Request) Result {
+	result := Result{Request: req}
+
+	m, err := d.manifest(filepath.Dir(req.Path))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for attempt := 0; ; attempt++ {
+		var retryAfter time.Duration
+		result.SavedPath, result.Cached, retryAfter, err = d.fetch(ctx, req, m)
+		if err == nil || retryAfter < 0 || attempt >= d.opts.Retries {
+			break
+		}
+
+		wait := max(retryAfter, retryBackoff<<attempt)
+		select {
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			return result
+		case <-time.After(min(wait, maxRetryWait)):
+		}
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("%s: %w", req.URL, err)
+	}
+
+	return result
+}
+
+// Download a file once
+//
+// retryAfter is negative when the error is permanent, otherwise it is the
+// wait requested by the server (0 if none).
+func (d *Downloader) fetch(ctx context.Context, req Request, m *manifest) (savedPath string, cached bool, retryAfter time.Duration, err error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", false, -1, fmt.Errorf("failed to create request: %w", err)
 	}
-	if provider == "GitHub" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", token))
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+
+	// Ask the server whether the file saved last time has changed
+	//
+	// The file is only reused if it was saved at the path of the request, since
+	// the names depend on the position of the attachment (e.g. img_1) and another
+	// URL may have been saved there since.
+	prev, hasPrev := m.get(req.URL)
+	hasPrev = hasPrev && strings.TrimSuffix(prev.File, filepath.Ext(prev.File)) == filepath.Base(req.Path)
+	if hasPrev && prev.exists(m.dir) {
+		if prev.ETag != "" {
+			httpReq.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			httpReq.Header.Set("If-Modified-Since", prev.LastModified)
+		}
 	}
 
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("failed to execute HTTP request: %w", err)
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf(
-			"failed to download file from %s: unexpected status code %d %s",
-			req.URL.String(),
-			resp.StatusCode,
-			http.StatusText(resp.StatusCode),
-		)
+		if ctx.Err() != nil {
+			return "", false, -1, ctx.Err()
+		}
+		return "", false, 0, fmt.Errorf("failed to execute HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Create file
-	out, err := os.Create(filePath)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasPrev && prev.exists(m.dir):
+		return filepath.Join(m.dir, prev.File), true, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return "", false, parseRetryAfter(resp.Header.Get("Retry-After")), unexpectedStatus(resp)
+	case resp.StatusCode != http.StatusOK:
+		return "", false, -1, unexpectedStatus(resp)
+	}
+
+	if resp.ContentLength > d.opts.MaxSize {
+		return "", false, -1, fmt.Errorf("file size %s exceeds the maximum size %s", size.Format(resp.ContentLength), size.Format(d.opts.MaxSize))
+	}
+
+	// Read the file into a temporary file next to the destination
+	tmp, err := os.CreateTemp(m.dir, ".download-*")
 	if err != nil {
-		return fmt.Errorf("file create error: %v", err)
+		return "", false, -1, fmt.Errorf("failed to create file: %w", err)
 	}
-	defer out.Close()
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	// Write to file
-	_, err = io.Copy(out, resp.Body)
+	hash := sha256.New()
+	sniff := &sniffWriter{}
+	n, err := io.Copy(io.MultiWriter(tmp, hash, sniff), io.LimitReader(resp.Body, d.opts.MaxSize+1))
 	if err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+		return "", false, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	if n > d.opts.MaxSize {
+		return "", false, -1, fmt.Errorf("file exceeds the maximum size %s", size.Format(d.opts.MaxSize))
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return "", false, -1, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", false, -1, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	entry := manifestEntry{
+		File:         filepath.Base(req.Path) + extension(req, resp.Header.Get("Content-Type"), sniff.data),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+	}
+	savedPath = filepath.Join(m.dir, entry.File)
+
+	// Keep the file as it is if the content has not changed
+	if hasPrev && prev.File == entry.File && prev.SHA256 == entry.SHA256 && prev.exists(m.dir) {
+		m.set(req.URL, entry)
+		return savedPath, true, 0, nil
+	}
+
+	if err := os.Rename(tmp.Name(), savedPath); err != nil {
+		return "", false, -1, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	// Remove the file saved last time under another name so that it isn't left behind
+	if stale := m.set(req.URL, entry); stale != "" {
+		os.Remove(filepath.Join(m.dir, stale))
+	}
+
+	return savedPath, false, 0, nil
+}
+
+// Manifest of the directory, loaded on first use
+func (d *Downloader) manifest(dir string) (*manifest, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if m, ok := d.manifests[dir]; ok {
+		return m, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
+	m, err := loadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	d.manifests[dir] = m
+
+	return m, nil
+}
+
+func unexpectedStatus(resp *http.Response) error {
+	return fmt.Errorf("unexpected status code %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+}
+
+// Wait requested by a Retry-After header in seconds (0 if not set or an HTTP date)
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// Keeps the first bytes written for content sniffing
+type sniffWriter struct {
+	data []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if rest := 512 - len(w.data); rest > 0 {
+		w.data = append(w.data, p[:min(rest, len(p))]...)
+	}
+
+	return len(p), nil
+}
+
+// Extension of the saved file
+//
+// The extension of the original file name is used if it is known, then the
+// one of the content type sent by the server (unless it is generic), then
+// the one of the sniffed content, and finally the one of the URL path.
+func extension(req Request, contentType string, head []byte) string {
+	if ext := path.Ext(req.FileName); ext != "" {
+		return strings.ToLower(ext)
+	}
+	if ext, ok := contentTypeExtensions[mediaType(contentType)]; ok {
+		return ext
+	}
+	if ext, ok := contentTypeExtensions[mediaType(http.DetectContentType(head))]; ok {
+		return ext
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 6 {
+			return strings.ToLower(ext)
+		}
+	}
+
+	return ".bin"
+}
+
+// Media type of a Content-Type header without parameters (e.g. "text/plain")
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
 
-	return nil
+// Extensions of the content types of common attachments
+var contentTypeExtensions = map[string]string{
+	"image/png":                    ".png",
+	"image/jpeg":                   ".jpg",
+	"image/gif":                    ".gif",
+	"image/webp":                   ".webp",
+	"image/bmp":                    ".bmp",
+	"image/svg+xml":                ".svg",
+	"image/x-icon":                 ".ico",
+	"image/avif":                   ".avif",
+	"image/heic":                   ".heic",
+	"video/mp4":                    ".mp4",
+	"video/quicktime":              ".mov",
+	"video/webm":                   ".webm",
+	"video/x-msvideo":              ".avi",
+	"audio/mpeg":                   ".mp3",
+	"audio/wave":                   ".wav",
+	"application/pdf":              ".pdf",
+	"application/zip":              ".zip",
+	"application/x-gzip":           ".gz",
+	"application/gzip":             ".gz",
+	"application/json":             ".json",
+	"text/plain":                   ".txt",
+	"text/csv":                     ".csv",
+	"text/html":                    ".html",
+	"text/xml":                     ".xml",
+	"application/xml":              ".xml",
+	"application/vnd.ms-excel":     ".xls",
+	"application/msword":           ".doc",
+	"application/x-tar":            ".tar",
+	"application/x-7z-compressed":  ".7z",
+	"application/x-rar-compressed": ".rar",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       ".xlsx",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
 }
@@ -0,0 +1,114 @@
+package download
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tomoyuki65/go-aidd/internal/util/size"
+)
+
+// Regexes to extract the targets of images and links in task bodies
+var linkRegexes = []*regexp.Regexp{
+	// Markdown images and links: ![alt](target "title") or [text](<target>)
+	regexp.MustCompile(`!?\[[^\]]*\]\(\s*<?([^\s)>]+)>?(?:\s+["'(][^)]*)?\s*\)`),
+	// HTML tags: <img src="target">, <a href="target">, <video src="target"> etc.
+	regexp.MustCompile(`(?i)<(?:img|a|video|audio|source)\b[^>]*?\b(?:src|href)\s*=\s*["']([^"']+)["']`),
+	// Bare URLs (e.g. videos pasted on their own line)
+	regexp.MustCompile(`(https?://[^\s<>()"'\[\]|]+[^\s<>()"'\[\]|.,;:!?])`),
+}
+
+// Targets of the images and links in the body (URLs or data URIs), unique and in order of appearance
+func Links(body string) []string {
+	type match struct {
+		pos    int
+		target string
+	}
+
+	var matches []match
+	for _, re := range linkRegexes {
+		for _, m := range re.FindAllStringSubmatchIndex(body, -1) {
+			matches = append(matches, match{pos: m[2], target: body[m[2]:m[3]]})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return a.pos - b.pos
+	})
+
+	var links []string
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.target] {
+			seen[m.target] = true
+			links = append(links, m.target)
+		}
+	}
+
+	return links
+}
+
+// Whether the target of a link is a data URI (e.g. data:image/png;base64,...)
+func IsDataURI(target string) bool {
+	return len(target) > 5 && strings.EqualFold(target[:5], "data:")
+}
+
+// Decode a data URI and save it to path with the extension of its media type
+//
+// The saved path is returned. As with downloads, the extension is taken from
+// the declared media type, then the sniffed content.
+func (d *Downloader) SaveData(path, uri string) (string, error) {
+	if !IsDataURI(uri) {
+		return "", errors.New("not a data URI")
+	}
+
+	header, payload, found := strings.Cut(uri[5:], ",")
+	if !found {
+		return "", errors.New("invalid data URI")
+	}
+
+	var data []byte
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if isBase64 {
+		// Line breaks and URL-safe encodings are seen in pasted data
+		payload = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, payload)
+		var err error
+		data, err = base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to decode data URI: %w", err)
+		}
+	} else {
+		decoded, err := url.PathUnescape(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode data URI: %w", err)
+		}
+		data = []byte(decoded)
+	}
+
+	if int64(len(data)) > d.opts.MaxSize {
+		return "", fmt.Errorf("data exceeds the maximum size %s", size.Format(d.opts.MaxSize))
+	}
+
+	savedPath := path + extension(Request{}, mediaType, data)
+	if err := os.MkdirAll(filepath.Dir(savedPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(savedPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return savedPath, nil
+}
@@ -0,0 +1,126 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Name of the manifest file in each destination directory
+const manifestFile = ".downloads.json"
+
+// File saved by a previous download
+type manifestEntry struct {
+	// Name of the file in the directory
+	File         string `json:"file"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	SHA256       string `json:"sha256"`
+}
+
+// Whether the saved file still exists
+func (e manifestEntry) exists(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, e.File))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Files saved in a directory by URL
+type manifest struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]manifestEntry
+	changed bool
+}
+
+func loadManifest(dir string) (*manifest, error) {
+	m := &manifest{dir: dir, entries: map[string]manifestEntry{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read download cache: %w", err)
+	}
+
+	// A broken manifest only means that the files are downloaded again
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		m.entries = map[string]manifestEntry{}
+	}
+
+	return m, nil
+}
+
+func (m *manifest) get(url string) (manifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[url]
+	return e, ok
+}
+
+// Record the file saved for the URL
+//
+// If the file saved for the URL before has another extension (e.g. img_1.jpg
+// replaced by img_1.png) and no other URL refers to it, its name is returned
+// so that it can be removed. Files of other names are kept, since the same
+// URL can be saved under several names.
+func (m *manifest) set(url string, e manifestEntry) (stale string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.entries[url]
+	if prev != e {
+		m.entries[url] = e
+		m.changed = true
+	}
+
+	// The file now has the content of this URL, so the other URLs saved to it must be downloaded again
+	for other, entry := range m.entries {
+		if other != url && entry.File == e.File {
+			delete(m.entries, other)
+			m.changed = true
+		}
+	}
+
+	// Only names of files in the directory are returned (the manifest may have been edited)
+	if !ok || prev.File == e.File || prev.File == manifestFile || filepath.Base(prev.File) != prev.File || !filepath.IsLocal(prev.File) {
+		return ""
+	}
+	if strings.TrimSuffix(prev.File, filepath.Ext(prev.File)) != strings.TrimSuffix(e.File, filepath.Ext(e.File)) {
+		return ""
+	}
+	for _, other := range m.entries {
+		if other.File == prev.File {
+			return ""
+		}
+	}
+
+	return prev.File
+}
+
+// Write the manifest if an entry has changed
+func (m *manifest) save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(m.dir, manifestFile), data, 0644); err != nil {
+		return err
+	}
+	m.changed = false
+
+	return nil
+}
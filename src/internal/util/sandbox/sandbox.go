@@ -0,0 +1,152 @@
+package sandbox
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the hidden subcommand that applies the sandbox and executes the AI command
+const Command = "__sandbox"
+
+// Paths that can be read (and executed) by default so that the AI tools and their toolchains work
+var defaultReadOnlyPaths = []string{
+	"/usr",
+	"/bin",
+	"/sbin",
+	"/lib",
+	"/lib32",
+	"/lib64",
+	"/etc",
+	"/opt",
+	"/proc",
+	"/sys",
+	"/run/systemd/resolve",
+}
+
+// Paths that can be written by default (e.g. /dev/null, /dev/tty, /dev/shm)
+var defaultReadWritePaths = []string{
+	"/dev",
+}
+
+// Restrictions of the sandboxed process
+type Options struct {
+	// Paths that can be read and written (e.g. the cloned repository)
+	ReadWritePaths []string
+	// Paths that can only be read (in addition to the system directories)
+	ReadOnlyPaths []string
+	// Run the process in its own network namespace without any network interface
+	DisableNetwork bool
+}
+
+// Wrap cmd so that it is executed inside the sandbox
+//
+// The aidd binary is executed again with the hidden subcommand, which
+// restricts itself and then replaces itself with the original command.
+func Wrap(cmd *exec.Cmd, opts Options) error {
+	if err := checkSupported(); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get the path of the executable: %w", err)
+	}
+
+	args := []string{Command}
+	for _, p := range opts.ReadWritePaths {
+		abs, err := expandPath(p)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-rw", abs)
+	}
+	for _, p := range opts.ReadOnlyPaths {
+		abs, err := expandPath(p)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-ro", abs)
+	}
+	args = append(args, "--", cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = exe
+	cmd.Args = append([]string{exe}, args...)
+
+	if opts.DisableNetwork {
+		return isolateNetwork(cmd)
+	}
+
+	return nil
+}
+
+// Entry point of the hidden subcommand (never returns on success)
+func Main(args []string) int {
+	var rw, ro pathList
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.Var(&rw, "rw", "path that can be read and written")
+	fs.Var(&ro, "ro", "path that can only be read")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fmt.Fprintln(os.Stderr, "sandbox: no command given")
+		return 2
+	}
+
+	rules := []rule{}
+	for _, p := range append(defaultReadWritePaths, rw...) {
+		rules = append(rules, rule{path: p, writable: true})
+	}
+	for _, p := range append(defaultReadOnlyPaths, ro...) {
+		rules = append(rules, rule{path: p})
+	}
+
+	// Only returns if the sandbox could not be applied or the command could not be executed
+	err := execRestricted(argv, rules)
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+
+	return 1
+}
+
+// Path that is accessible inside the sandbox
+type rule struct {
+	path     string
+	writable bool
+}
+
+// Repeatable path flag
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// Resolve "~" and relative paths to absolute paths
+func expandPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get the home directory: %w", err)
+		}
+		p = filepath.Join(home, p[1:])
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", p, err)
+	}
+
+	return abs, nil
+}
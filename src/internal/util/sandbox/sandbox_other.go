@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errNotSupported = errors.New("the sandbox is only supported on Linux")
+
+func checkSupported() error {
+	return errNotSupported
+}
+
+func isolateNetwork(cmd *exec.Cmd) error {
+	return errNotSupported
+}
+
+func execRestricted(argv []string, rules []rule) error {
+	return errNotSupported
+}
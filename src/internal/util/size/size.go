@@ -0,0 +1,63 @@
+package size
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Units of sizes (binary multiples, e.g. 4G = 4 * 1024^3 bytes)
+var units = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// Parse a size such as "512M", "4G" or "1.5GiB" (an empty value is 0)
+func Parse(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	value := strings.ToUpper(s)
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSuffix(value, u.suffix)
+			multiplier = u.bytes
+			break
+		}
+	}
+
+	// Inf and NaN are accepted by ParseFloat but are not sizes
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("%q is not a size (e.g. 512M, 4G)", s)
+	}
+
+	// float64(math.MaxInt64) is 2^63, which doesn't fit in an int64
+	bytes := n * float64(multiplier)
+	if bytes >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+
+	return int64(bytes), nil
+}
+
+// Format a size in bytes for messages (e.g. 1.5G)
+func Format(n int64) string {
+	for _, u := range units {
+		if n >= u.bytes {
+			return strings.TrimSuffix(strconv.FormatFloat(float64(n)/float64(u.bytes), 'f', 1, 64), ".0") + u.suffix
+		}
+	}
+
+	return strconv.FormatInt(n, 10) + "B"
+}